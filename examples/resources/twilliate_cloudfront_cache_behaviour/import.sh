@@ -0,0 +1,2 @@
+# Cache behaviours can be imported using the distribution id and the path pattern
+terraform import twilliate_cloudfront_cache_behaviour.example 'E1WO5WCDX9Q7CD//impressum*'
@@ -0,0 +1,2 @@
+# Origins can be imported using the distribution id and the origin id
+terraform import twilliate_cloudfront_origin.example E1WO5WCDX9Q7CD/impressum
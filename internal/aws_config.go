@@ -0,0 +1,286 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"golang.org/x/exp/slices"
+	"time"
+)
+
+type AssumeRole struct {
+	RoleArn     types.String `tfsdk:"role_arn"`
+	SessionName types.String `tfsdk:"session_name"`
+	ExternalId  types.String `tfsdk:"external_id"`
+	Duration    types.String `tfsdk:"duration"`
+	Policy      types.String `tfsdk:"policy"`
+}
+
+type AssumeRoleWithWebIdentity struct {
+	RoleArn              types.String `tfsdk:"role_arn"`
+	SessionName          types.String `tfsdk:"session_name"`
+	WebIdentityToken     types.String `tfsdk:"web_identity_token"`
+	WebIdentityTokenFile types.String `tfsdk:"web_identity_token_file"`
+	Duration             types.String `tfsdk:"duration"`
+	Policy               types.String `tfsdk:"policy"`
+}
+
+type Endpoints struct {
+	CloudFront types.String `tfsdk:"cloudfront"`
+	STS        types.String `tfsdk:"sts"`
+}
+
+func endpointsAttribute() tfsdk.Attribute {
+	return tfsdk.Attribute{
+		Optional: true,
+		Attributes: tfsdk.SingleNestedAttributes(map[string]tfsdk.Attribute{
+			"cloudfront": {
+				Type:     types.StringType,
+				Optional: true,
+			},
+			"sts": {
+				Type:     types.StringType,
+				Optional: true,
+			},
+		}),
+	}
+}
+
+func assumeRoleAttribute() tfsdk.Attribute {
+	return tfsdk.Attribute{
+		Optional: true,
+		Attributes: tfsdk.SingleNestedAttributes(map[string]tfsdk.Attribute{
+			"role_arn": {
+				Type:     types.StringType,
+				Required: true,
+			},
+			"session_name": {
+				Type:     types.StringType,
+				Optional: true,
+			},
+			"external_id": {
+				Type:     types.StringType,
+				Optional: true,
+			},
+			"duration": {
+				Type:       types.StringType,
+				Optional:   true,
+				Validators: []tfsdk.AttributeValidator{durationValidator{}},
+			},
+			"policy": {
+				Type:     types.StringType,
+				Optional: true,
+			},
+		}),
+	}
+}
+
+func assumeRoleWithWebIdentityAttribute() tfsdk.Attribute {
+	return tfsdk.Attribute{
+		Optional: true,
+		Attributes: tfsdk.SingleNestedAttributes(map[string]tfsdk.Attribute{
+			"role_arn": {
+				Type:     types.StringType,
+				Required: true,
+			},
+			"session_name": {
+				Type:     types.StringType,
+				Optional: true,
+			},
+			"web_identity_token": {
+				Type:      types.StringType,
+				Optional:  true,
+				Sensitive: true,
+			},
+			"web_identity_token_file": {
+				Type:     types.StringType,
+				Optional: true,
+			},
+			"duration": {
+				Type:       types.StringType,
+				Optional:   true,
+				Validators: []tfsdk.AttributeValidator{durationValidator{}},
+			},
+			"policy": {
+				Type:     types.StringType,
+				Optional: true,
+			},
+		}),
+	}
+}
+
+// identityToken is a web identity token which has been configured directly
+// instead of in a file.
+type identityToken string
+
+func (t identityToken) GetIdentityToken() ([]byte, error) {
+	return []byte(t), nil
+}
+
+// loadAwsConfig loads the shared AWS configuration with the overrides of
+// the provider configuration. A web identity is assumed before the role, so
+// a role can be assumed with the credentials of an OIDC runner.
+func loadAwsConfig(ctx context.Context, providerConfig providerData) (aws.Config, error) {
+	var options []func(*config.LoadOptions) error
+	if !providerConfig.Region.IsNull() {
+		options = append(options, config.WithRegion(providerConfig.Region.Value))
+	}
+	if !providerConfig.Profile.IsNull() {
+		options = append(options, config.WithSharedConfigProfile(providerConfig.Profile.Value))
+	}
+	if len(providerConfig.SharedConfigFiles) > 0 {
+		options = append(options, config.WithSharedConfigFiles(toStrings(providerConfig.SharedConfigFiles)))
+	}
+	if len(providerConfig.SharedCredentialsFiles) > 0 {
+		options = append(options, config.WithSharedCredentialsFiles(toStrings(providerConfig.SharedCredentialsFiles)))
+	}
+
+	if providerConfig.AccessKey.IsNull() != providerConfig.SecretKey.IsNull() {
+		return aws.Config{}, errors.New("access_key and secret_key must be set together")
+	}
+	if !providerConfig.Token.IsNull() && providerConfig.AccessKey.IsNull() {
+		return aws.Config{}, errors.New("token must be set together with access_key and secret_key")
+	}
+	if !providerConfig.AccessKey.IsNull() {
+		options = append(options, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
+			providerConfig.AccessKey.Value,
+			providerConfig.SecretKey.Value,
+			providerConfig.Token.Value,
+		)))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return aws.Config{}, err
+	}
+
+	if webIdentity := providerConfig.AssumeRoleWithWebIdentity; webIdentity != nil {
+		var tokenRetriever stscreds.IdentityTokenRetriever
+		switch {
+		case !webIdentity.WebIdentityToken.IsNull() && !webIdentity.WebIdentityTokenFile.IsNull():
+			return aws.Config{}, errors.New("assume_role_with_web_identity accepts either web_identity_token or web_identity_token_file")
+		case !webIdentity.WebIdentityToken.IsNull():
+			tokenRetriever = identityToken(webIdentity.WebIdentityToken.Value)
+		case !webIdentity.WebIdentityTokenFile.IsNull():
+			tokenRetriever = stscreds.IdentityTokenFile(webIdentity.WebIdentityTokenFile.Value)
+		default:
+			return aws.Config{}, errors.New("assume_role_with_web_identity needs either web_identity_token or web_identity_token_file")
+		}
+
+		duration, err := parseOptionalDuration(webIdentity.Duration)
+		if err != nil {
+			return aws.Config{}, err
+		}
+
+		credentialsProvider := stscreds.NewWebIdentityRoleProvider(newStsClient(cfg, providerConfig.Endpoints), webIdentity.RoleArn.Value, tokenRetriever, func(options *stscreds.WebIdentityRoleOptions) {
+			options.RoleSessionName = webIdentity.SessionName.Value
+			options.Duration = duration
+			options.Policy = toStringOrNil(webIdentity.Policy)
+		})
+		cfg.Credentials = aws.NewCredentialsCache(credentialsProvider)
+	}
+
+	if assumeRole := providerConfig.AssumeRole; assumeRole != nil {
+		duration, err := parseOptionalDuration(assumeRole.Duration)
+		if err != nil {
+			return aws.Config{}, err
+		}
+
+		credentialsProvider := stscreds.NewAssumeRoleProvider(newStsClient(cfg, providerConfig.Endpoints), assumeRole.RoleArn.Value, func(options *stscreds.AssumeRoleOptions) {
+			options.RoleSessionName = assumeRole.SessionName.Value
+			options.ExternalID = toStringOrNil(assumeRole.ExternalId)
+			options.Duration = duration
+			options.Policy = toStringOrNil(assumeRole.Policy)
+		})
+		cfg.Credentials = aws.NewCredentialsCache(credentialsProvider)
+	}
+
+	return cfg, nil
+}
+
+// parseOptionalDuration parses a duration attribute, null means the default
+// of the SDK.
+func parseOptionalDuration(value types.String) (time.Duration, error) {
+	if value.IsNull() {
+		return 0, nil
+	}
+	return time.ParseDuration(value.Value)
+}
+
+// newCloudfrontClient creates the CloudFront client with the configured
+// endpoint, retry policy and rate limit. The client is shared by all
+// resources, so the rate limit applies to all of their requests.
+func newCloudfrontClient(cfg aws.Config, providerConfig providerData) *cloudfront.Client {
+	if !providerConfig.MaxRetries.IsNull() || !providerConfig.RetryMode.IsNull() {
+		// the retryer of the shared config would override the retry policy
+		cfg.Retryer = nil
+	}
+	if !providerConfig.MaxRetries.IsNull() {
+		cfg.RetryMaxAttempts = int(providerConfig.MaxRetries.Value) + 1
+	}
+	if !providerConfig.RetryMode.IsNull() {
+		cfg.RetryMode = aws.RetryMode(providerConfig.RetryMode.Value)
+	}
+
+	if !providerConfig.MaxRequestsPerSecond.IsNull() {
+		httpClient := cfg.HTTPClient
+		if httpClient == nil {
+			httpClient = awshttp.NewBuildableClient()
+		}
+		cfg.HTTPClient = rateLimitedClient{
+			client: httpClient,
+			bucket: newTokenBucket(providerConfig.MaxRequestsPerSecond.Value),
+		}
+	}
+
+	endpoints := providerConfig.Endpoints
+	return cloudfront.NewFromConfig(cfg, func(options *cloudfront.Options) {
+		if endpoints != nil && !endpoints.CloudFront.IsNull() {
+			// CloudFront is a global service signed for us-east-1
+			options.EndpointResolver = cloudfront.EndpointResolverFromURL(endpoints.CloudFront.Value, func(endpoint *aws.Endpoint) {
+				endpoint.SigningRegion = "us-east-1"
+			})
+		}
+	})
+}
+
+// newStsClient creates an STS client, with the endpoint overridden if
+// configured.
+func newStsClient(cfg aws.Config, endpoints *Endpoints) *sts.Client {
+	return sts.NewFromConfig(cfg, func(options *sts.Options) {
+		if endpoints != nil && !endpoints.STS.IsNull() {
+			options.EndpointResolver = sts.EndpointResolverFromURL(endpoints.STS.Value)
+		}
+	})
+}
+
+// callerAccountId returns the id of the account the credentials belong to.
+func callerAccountId(ctx context.Context, client *sts.Client) (string, error) {
+	identity, err := client.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(identity.Account), nil
+}
+
+// checkAccountId verifies that the account is allowed and not forbidden,
+// without allowed account ids every account which is not forbidden is
+// allowed.
+func checkAccountId(accountId string, allowedAccountIds []string, forbiddenAccountIds []string) error {
+	if slices.Contains(forbiddenAccountIds, accountId) {
+		return fmt.Errorf("the aws credentials belong to account %s, which is listed in forbidden_account_ids", accountId)
+	}
+	if len(allowedAccountIds) > 0 && !slices.Contains(allowedAccountIds, accountId) {
+		return fmt.Errorf("the aws credentials belong to account %s, which is not listed in allowed_account_ids %v", accountId, allowedAccountIds)
+	}
+	return nil
+}
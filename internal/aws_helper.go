@@ -1,8 +1,13 @@
 package internal
 
 import (
+	"errors"
+	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	cloudfrontTypes "github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"golang.org/x/exp/slices"
+	"strings"
 )
 
 func toInt32(value types.Int64) *int32 {
@@ -12,6 +17,13 @@ func toInt32(value types.Int64) *int32 {
 	return aws.Int32(int32(value.Value))
 }
 
+func toInt64(value types.Int64) *int64 {
+	if value.IsNull() {
+		return nil
+	}
+	return aws.Int64(value.Value)
+}
+
 func toString(value types.String) *string {
 	if value.IsNull() {
 		return aws.String("")
@@ -32,3 +44,100 @@ func toBool(value types.Bool, defaults bool) *bool {
 	}
 	return aws.Bool(value.Value)
 }
+
+func fromInt32(value *int32) types.Int64 {
+	if value == nil {
+		return types.Int64{Null: true}
+	}
+	return types.Int64{Value: int64(*value)}
+}
+
+func fromInt64(value *int64) types.Int64 {
+	if value == nil {
+		return types.Int64{Null: true}
+	}
+	return types.Int64{Value: *value}
+}
+
+func fromString(value *string) types.String {
+	if value == nil || *value == "" {
+		return types.String{Null: true}
+	}
+	return types.String{Value: *value}
+}
+
+func fromBool(value *bool) types.Bool {
+	if value == nil {
+		return types.Bool{Null: true}
+	}
+	return types.Bool{Value: *value}
+}
+
+// keepEmptyString keeps an explicitly empty prior value, which CloudFront
+// reports the same way as an unset one.
+func keepEmptyString(prior types.String, current types.String) types.String {
+	if !prior.IsNull() && !prior.IsUnknown() && prior.Value == "" && current.IsNull() {
+		return prior
+	}
+	return current
+}
+
+func toStrings(values []types.String) []string {
+	var items []string
+	for _, value := range values {
+		items = append(items, value.Value)
+	}
+	return items
+}
+
+func fromStrings(values []string) []types.String {
+	var items []types.String
+	for _, value := range values {
+		items = append(items, types.String{Value: value})
+	}
+	return items
+}
+
+func sameStrings(a []types.String, b []types.String) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, value := range a {
+		if !slices.Contains(b, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// keepOrder keeps the prior order of a list CloudFront might return in a
+// different order.
+func keepOrder(prior []types.String, current []types.String) []types.String {
+	if sameStrings(prior, current) {
+		return prior
+	}
+	return current
+}
+
+// isNoSuchDistribution reports whether the distribution has been deleted.
+func isNoSuchDistribution(err error) bool {
+	var noSuchDistribution *cloudfrontTypes.NoSuchDistribution
+	return errors.As(err, &noSuchDistribution)
+}
+
+// splitImportId splits an import identifier of the form
+// <distribution_id>/<identifier> into its parts.
+func splitImportId(id string, identifier string) (string, string, error) {
+	parts := strings.SplitN(id, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("expected an import id of the form <distribution_id>/<%s>, got %q", identifier, id)
+	}
+	return parts[0], parts[1], nil
+}
+
+// isPreconditionFailed reports whether the ETag of an update did not match,
+// because the distribution has been changed in between.
+func isPreconditionFailed(err error) bool {
+	var preconditionFailed *cloudfrontTypes.PreconditionFailed
+	return errors.As(err, &preconditionFailed)
+}
@@ -5,13 +5,20 @@ import (
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
-	"github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	cloudfrontTypes "github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
 	"golang.org/x/exp/slices"
 )
 
 type CacheBehaviourResource struct {
-	client *cloudfront.Client
+	client            *cloudfront.Client
+	distributions     *distributionUpdater
+	origins           *plannedOrigins
+	allowlist         *distributionAllowlist
+	waitForDeployment bool
 }
 
 // Create is called when the provider must create a new resource. Config
@@ -26,37 +33,34 @@ func (c CacheBehaviourResource) Create(ctx context.Context, req tfsdk.CreateReso
 		return
 	}
 
-	out, err := c.client.GetDistributionConfig(ctx, &cloudfront.GetDistributionConfigInput{
-		Id: aws.String(plan.DistributionId.Value),
-	})
-
-	if err != nil {
-		resp.Diagnostics.AddError("failed to get distribution config", err.Error())
-		return
-	}
+	ctx, cancel := withTimeout(ctx, plan.Timeouts.create())
+	defer cancel()
 
-	distributionConfig := out.DistributionConfig
-	distributionConfig.CacheBehaviors.Items = append(distributionConfig.CacheBehaviors.Items, plan.ToCloudfrontCacheBehaviour())
-	*distributionConfig.CacheBehaviors.Quantity++
-	// Add new Cache Behaviour to existing configuration
+	plan = plan.withDefaultTTLs()
 
-	_, err = c.client.UpdateDistribution(ctx, &cloudfront.UpdateDistributionInput{
-		DistributionConfig: distributionConfig,
-		Id:                 aws.String(plan.DistributionId.Value),
-		IfMatch:            out.ETag,
-	})
+	var precedence int
+	err := c.distributions.updateInOrder(ctx, plan.DistributionId.Value, plan.insertOrder(), plan.createMutation(&precedence))
 
 	if err != nil {
 		resp.Diagnostics.AddError("failed to create origin in distribution", err.Error())
 		return
 	}
 
+	plan.Id = types.String{Value: cacheBehaviourId(plan.DistributionId.Value, plan.PathPattern.Value)}
+	plan.Precedence = types.Int64{Value: int64(precedence)}
 	resp.State.Set(ctx, &plan)
 	resp.Diagnostics.Append(diags...)
 
 	if resp.Diagnostics.HasError() {
 		return
 	}
+
+	if *toBool(plan.WaitForDeployment, c.waitForDeployment) {
+		err = c.distributions.waitForDeployment(ctx, plan.DistributionId.Value)
+		if err != nil {
+			resp.Diagnostics.AddError("failed to wait for deployment of distribution", err.Error())
+		}
+	}
 }
 
 // Read is called when the provider must read resource values in order
@@ -71,6 +75,34 @@ func (c CacheBehaviourResource) Read(ctx context.Context, req tfsdk.ReadResource
 		return
 	}
 
+	out, err := c.client.GetDistributionConfig(ctx, &cloudfront.GetDistributionConfigInput{
+		Id: aws.String(state.DistributionId.Value),
+	})
+
+	if isNoSuchDistribution(err) {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	if err != nil {
+		resp.Diagnostics.AddError("failed to get distribution config", err.Error())
+		return
+	}
+
+	idx := state.indexIn(out.DistributionConfig.CacheBehaviors.Items)
+
+	// the cache behaviour has been removed outside of terraform
+	if idx == -1 {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	refreshed := CacheBehaviourFromCloudfront(state.DistributionId.Value, idx, out.DistributionConfig.CacheBehaviors.Items[idx]).keepUnsetDefaults(state)
+	refreshed.WaitForDeployment = state.WaitForDeployment
+	refreshed.Timeouts = state.Timeouts
+	refreshed.MoveInPlace = state.MoveInPlace
+	state = refreshed
+
 	diags = resp.State.Set(ctx, &state)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
@@ -99,53 +131,83 @@ func (c CacheBehaviourResource) Update(ctx context.Context, req tfsdk.UpdateReso
 		return
 	}
 
-	if state.DistributionId.Value != plan.DistributionId.Value {
-		err := c.deleteFromDistribution(ctx, state)
-		if err != nil {
-			resp.Diagnostics.AddError("failed to remove cache behaviour from previous distribution", err.Error())
-		}
-	}
+	ctx, cancel := withTimeout(ctx, plan.Timeouts.update())
+	defer cancel()
 
-	out, err := c.client.GetDistributionConfig(ctx, &cloudfront.GetDistributionConfigInput{
-		Id: aws.String(plan.DistributionId.Value),
-	})
+	plan = plan.withDefaultTTLs()
 
-	if err != nil {
-		resp.Diagnostics.AddError("failed to get distribution config", err.Error())
+	// the precedence of the prior refresh is no position to move to
+	var configPrecedence types.Int64
+	diags = req.Config.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("precedence"), &configPrecedence)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
 		return
 	}
+	if configPrecedence.IsNull() {
+		plan.Precedence = types.Int64{Unknown: true}
+	}
 
-	distributionConfig := out.DistributionConfig
-
-	// TODO: create internal computed id instead of this hack
-	idx := slices.IndexFunc(distributionConfig.CacheBehaviors.Items, func(behaviour types.CacheBehavior) bool {
-		return *behaviour.TargetOriginId == state.OriginId.Value && *behaviour.PathPattern == state.PathPattern.Value
-	})
-
-	if idx == -1 {
-		distributionConfig.CacheBehaviors.Items = append(distributionConfig.CacheBehaviors.Items, plan.ToCloudfrontCacheBehaviour())
-		*distributionConfig.CacheBehaviors.Quantity++
-	} else {
-		distributionConfig.CacheBehaviors.Items[idx] = plan.ToCloudfrontCacheBehaviour()
+	// distribution changed with move_in_place, remove cache behaviour from old distribution
+	moved := state.DistributionId.Value != plan.DistributionId.Value
+	if moved {
+		err := c.deleteFromDistribution(ctx, state)
+		if err != nil {
+			resp.Diagnostics.AddError("failed to remove cache behaviour from previous distribution", err.Error())
+			return
+		}
 	}
 
-	_, err = c.client.UpdateDistribution(ctx, &cloudfront.UpdateDistributionInput{
-		DistributionConfig: distributionConfig,
-		Id:                 aws.String(plan.DistributionId.Value),
-		IfMatch:            out.ETag,
-	})
+	var precedence int
+	err := c.distributions.updateInOrder(ctx, plan.DistributionId.Value, plan.insertOrder(), plan.updateMutation(state, moved, &precedence))
+
+	if err != nil && moved {
+		// restore the cache behaviour in the previous distribution, the context of the
+		// operation might already be done
+		rollbackCtx, cancel := context.WithTimeout(context.Background(), rollbackTimeout)
+		rollbackErr := c.distributions.update(rollbackCtx, state.DistributionId.Value, func(distributionConfig *cloudfrontTypes.DistributionConfig) error {
+			if state.indexIn(distributionConfig.CacheBehaviors.Items) != -1 {
+				return errUnchanged
+			}
+			behaviour := state
+			// the previous position might no longer exist, so append it instead
+			if behaviour.Precedence.Value > int64(len(distributionConfig.CacheBehaviors.Items)) {
+				behaviour.Precedence = types.Int64{Null: true}
+			}
+			items, _, err := behaviour.insertInto(distributionConfig.CacheBehaviors.Items)
+			if err != nil {
+				return err
+			}
+			distributionConfig.CacheBehaviors.Items = items
+			*distributionConfig.CacheBehaviors.Quantity++
+			return nil
+		})
+		cancel()
+
+		if rollbackErr != nil {
+			resp.Diagnostics.AddError("failed to restore cache behaviour in previous distribution", rollbackErr.Error())
+		}
+	}
 
 	if err != nil {
 		resp.Diagnostics.AddError("failed to update distribution", err.Error())
 		return
 	}
 
+	plan.Id = types.String{Value: cacheBehaviourId(plan.DistributionId.Value, plan.PathPattern.Value)}
+	plan.Precedence = types.Int64{Value: int64(precedence)}
 	resp.State.Set(ctx, &plan)
 	resp.Diagnostics.Append(diags...)
 
 	if resp.Diagnostics.HasError() {
 		return
 	}
+
+	if *toBool(plan.WaitForDeployment, c.waitForDeployment) {
+		err = c.distributions.waitForDeployment(ctx, plan.DistributionId.Value)
+		if err != nil {
+			resp.Diagnostics.AddError("failed to wait for deployment of distribution", err.Error())
+		}
+	}
 }
 
 // Delete is called when the provider must delete the resource. Config
@@ -162,42 +224,312 @@ func (c CacheBehaviourResource) Delete(ctx context.Context, req tfsdk.DeleteReso
 		return
 	}
 
+	ctx, cancel := withTimeout(ctx, state.Timeouts.delete())
+	defer cancel()
+
 	err := c.deleteFromDistribution(ctx, state)
 
-	// Its okay if the behaviour has already been deleted
 	if err != nil {
-		resp.Diagnostics.AddWarning("failed to delete cache behaviour from distribution", err.Error())
+		resp.Diagnostics.AddError("failed to delete cache behaviour from distribution", err.Error())
+		return
+	}
+
+	if *toBool(state.WaitForDeployment, c.waitForDeployment) {
+		err = c.distributions.waitForDeployment(ctx, state.DistributionId.Value)
+		if err != nil {
+			resp.Diagnostics.AddError("failed to wait for deployment of distribution", err.Error())
+			return
+		}
 	}
 
 	resp.State.RemoveResource(ctx)
 }
 
 func (c CacheBehaviourResource) deleteFromDistribution(ctx context.Context, state CacheBehaviour) error {
-	out, err := c.client.GetDistributionConfig(ctx, &cloudfront.GetDistributionConfigInput{
-		Id: aws.String(state.DistributionId.Value),
+	err := c.distributions.update(ctx, state.DistributionId.Value, func(distributionConfig *cloudfrontTypes.DistributionConfig) error {
+		idx := state.indexIn(distributionConfig.CacheBehaviors.Items)
+
+		// the cache behaviour has already been removed
+		if idx == -1 {
+			return errUnchanged
+		}
+
+		distributionConfig.CacheBehaviors.Items = append(distributionConfig.CacheBehaviors.Items[:idx], distributionConfig.CacheBehaviors.Items[idx+1:]...)
+		*distributionConfig.CacheBehaviors.Quantity--
+		return nil
 	})
 
+	// the distribution and the cache behaviour with it are already gone
+	if isNoSuchDistribution(err) {
+		return nil
+	}
+
+	return err
+}
+
+// ImportState is called when the provider must import the state of a cache
+// behaviour. The import id has the form <distribution_id>/<path_pattern>,
+// Read then populates the remaining attributes from the distribution config.
+func (c CacheBehaviourResource) ImportState(ctx context.Context, req tfsdk.ImportResourceStateRequest, resp *tfsdk.ImportResourceStateResponse) {
+	distributionId, pathPattern, err := splitImportId(req.ID, "path_pattern")
 	if err != nil {
-		return err
+		resp.Diagnostics.AddError("invalid import id", err.Error())
+		return
 	}
 
-	// TODO: create internal computed id instead of this hack
-	idx := slices.IndexFunc(out.DistributionConfig.CacheBehaviors.Items, func(behaviour types.CacheBehavior) bool {
-		return *behaviour.TargetOriginId == state.OriginId.Value && *behaviour.PathPattern == state.PathPattern.Value
-	})
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("id"), cacheBehaviourId(distributionId, pathPattern))...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("distribution_id"), distributionId)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("path_pattern"), pathPattern)...)
+}
 
-	if idx == -1 {
-		return fmt.Errorf("the cache behaviour with origin id %s and path %s can not be found, it has been modified or removed", state.DistributionId, state.PathPattern)
+// ModifyPlan is called when the provider has an opportunity to modify the
+// plan. The id is derived from the planned distribution and path pattern,
+// so it is known before apply. It also verifies that the targeted origin
+// exists in the distribution or is planned in the same run.
+func (c CacheBehaviourResource) ModifyPlan(ctx context.Context, req tfsdk.ModifyResourcePlanRequest, resp *tfsdk.ModifyResourcePlanResponse) {
+	resp.Diagnostics.Append(c.allowlist.verifyPlan(ctx, req)...)
+	if resp.Diagnostics.HasError() {
+		return
 	}
 
-	out.DistributionConfig.CacheBehaviors.Items = append(out.DistributionConfig.CacheBehaviors.Items[:idx], out.DistributionConfig.CacheBehaviors.Items[idx+1:]...)
-	*out.DistributionConfig.CacheBehaviors.Quantity--
+	// resource is being destroyed
+	if req.Plan.Raw.IsNull() {
+		return
+	}
 
-	_, err = c.client.UpdateDistribution(ctx, &cloudfront.UpdateDistributionInput{
-		DistributionConfig: out.DistributionConfig,
-		Id:                 aws.String(state.DistributionId.Value),
-		IfMatch:            out.ETag,
-	})
+	var distributionId, pathPattern, originId types.String
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("distribution_id"), &distributionId)...)
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("path_pattern"), &pathPattern)...)
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("origin_id"), &originId)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
 
-	return err
+	if !distributionId.Unknown && !pathPattern.Unknown {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("id"), cacheBehaviourId(distributionId.Value, pathPattern.Value))...)
+	}
+
+	resp.Diagnostics.Append(planTTLs(ctx, req.Config, &resp.Plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// without a configured precedence, an updated cache behaviour keeps its
+	// current position, which might have been shifted by other updates of the
+	// same run, and a cache behaviour moved in place is appended to the new
+	// distribution
+	if !req.State.Raw.IsNull() && !resp.Plan.Raw.Equal(req.State.Raw) {
+		var configPrecedence types.Int64
+		resp.Diagnostics.Append(req.Config.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("precedence"), &configPrecedence)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+
+		if configPrecedence.IsNull() {
+			resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("precedence"), types.Int64{Unknown: true})...)
+		}
+	}
+
+	if distributionId.Unknown || originId.Unknown {
+		return
+	}
+
+	// the target has already been verified and CloudFront prevents deleting targeted origins
+	if !req.State.Raw.IsNull() {
+		var stateDistributionId, stateOriginId types.String
+		resp.Diagnostics.Append(req.State.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("distribution_id"), &stateDistributionId)...)
+		resp.Diagnostics.Append(req.State.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("origin_id"), &stateOriginId)...)
+		if resp.Diagnostics.HasError() || (stateDistributionId == distributionId && stateOriginId == originId) {
+			return
+		}
+	}
+
+	// a created cache behaviour fails on apply if the origin is still missing
+	resp.Diagnostics.Append(verifyPlannedOrigin(ctx, c.client, c.origins, distributionId.Value, originId.Value, tftypes.NewAttributePath().WithAttributeName("origin_id"), req.State.Raw.IsNull())...)
+}
+
+// planTTLs plans the TTLs CloudFront uses for forwarded values which do not
+// configure them, without forwarded values the TTLs are not used.
+func planTTLs(ctx context.Context, config tfsdk.Config, plan *tfsdk.Plan) diag.Diagnostics {
+	var diags diag.Diagnostics
+	var forwardedValues types.Object
+	var minTTL, defaultTTL, maxTTL types.Int64
+	diags.Append(config.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("forwarded_values"), &forwardedValues)...)
+	diags.Append(config.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("min_ttl"), &minTTL)...)
+	diags.Append(config.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("default_ttl"), &defaultTTL)...)
+	diags.Append(config.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("max_ttl"), &maxTTL)...)
+	if diags.HasError() {
+		return diags
+	}
+
+	if !forwardedValues.IsNull() {
+		minTTL, defaultTTL, maxTTL = defaultTTLs(minTTL, defaultTTL, maxTTL)
+	}
+
+	diags.Append(plan.SetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("min_ttl"), minTTL)...)
+	diags.Append(plan.SetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("default_ttl"), defaultTTL)...)
+	diags.Append(plan.SetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("max_ttl"), maxTTL)...)
+	return diags
+}
+
+// ValidateConfig validates combinations of attributes which the schema can
+// not express.
+func (c CacheBehaviourResource) ValidateConfig(ctx context.Context, req tfsdk.ValidateResourceConfigRequest, resp *tfsdk.ValidateResourceConfigResponse) {
+	allowedMethodsPath := tftypes.NewAttributePath().WithAttributeName("allowed_methods")
+	var allowedMethods, cachedMethods types.List
+	var functionAssociations, lambdaFunctionAssociations types.List
+	var trustedKeyGroupsEnabled, trustedSignersEnabled types.Bool
+	var cachePolicyId types.String
+	var forwardedValues types.Object
+	var minTTL, defaultTTL, maxTTL types.Int64
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, allowedMethodsPath.WithAttributeName("allowed_methods"), &allowedMethods)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, allowedMethodsPath.WithAttributeName("cached_methods"), &cachedMethods)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("function_associations"), &functionAssociations)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("lambda_function_associations"), &lambdaFunctionAssociations)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("trusted_key_groups").WithAttributeName("enabled"), &trustedKeyGroupsEnabled)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("trusted_signers").WithAttributeName("enabled"), &trustedSignersEnabled)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("cache_policy_id"), &cachePolicyId)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("forwarded_values"), &forwardedValues)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("min_ttl"), &minTTL)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("default_ttl"), &defaultTTL)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("max_ttl"), &maxTTL)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if !cachePolicyId.IsNull() && !forwardedValues.IsNull() {
+		resp.Diagnostics.AddAttributeError(
+			tftypes.NewAttributePath().WithAttributeName("forwarded_values"),
+			"Conflicting cache settings",
+			"a cache behaviour can either use a cache policy or the legacy forwarded values, set only one of cache_policy_id and forwarded_values",
+		)
+	} else if cachePolicyId.IsNull() && forwardedValues.IsNull() {
+		resp.Diagnostics.AddError(
+			"Missing cache settings",
+			"a cache behaviour needs either cache_policy_id or forwarded_values",
+		)
+	}
+
+	ttls := []struct {
+		name  string
+		value types.Int64
+	}{{"min_ttl", minTTL}, {"default_ttl", defaultTTL}, {"max_ttl", maxTTL}}
+	for _, ttl := range ttls {
+		if !ttl.value.IsNull() && forwardedValues.IsNull() {
+			resp.Diagnostics.AddAttributeError(
+				tftypes.NewAttributePath().WithAttributeName(ttl.name),
+				"Unused TTL",
+				fmt.Sprintf("%s only applies to forwarded_values, the TTLs of a cache policy are part of the policy", ttl.name),
+			)
+		}
+	}
+	if !minTTL.IsNull() && !minTTL.IsUnknown() && !defaultTTL.IsNull() && !defaultTTL.IsUnknown() && minTTL.Value > defaultTTL.Value {
+		resp.Diagnostics.AddAttributeError(
+			tftypes.NewAttributePath().WithAttributeName("default_ttl"),
+			"Invalid TTL",
+			fmt.Sprintf("default_ttl must not be less than min_ttl %d, got %d", minTTL.Value, defaultTTL.Value),
+		)
+	}
+	if !defaultTTL.IsNull() && !defaultTTL.IsUnknown() && !maxTTL.IsNull() && !maxTTL.IsUnknown() && defaultTTL.Value > maxTTL.Value {
+		resp.Diagnostics.AddAttributeError(
+			tftypes.NewAttributePath().WithAttributeName("max_ttl"),
+			"Invalid TTL",
+			fmt.Sprintf("max_ttl must not be less than default_ttl %d, got %d", defaultTTL.Value, maxTTL.Value),
+		)
+	}
+
+	// without allowed methods the defaults of ToCloudfrontAllowedMethods apply
+	allowed, allowedKnown := knownStrings(allowedMethods)
+	if allowedKnown && len(allowed) == 0 {
+		for _, method := range defaultAllowedMethods {
+			allowed = append(allowed, string(method))
+		}
+	}
+	if cached, cachedKnown := knownStrings(cachedMethods); allowedKnown && cachedKnown {
+		for i, method := range cached {
+			if !slices.Contains(allowed, method) {
+				resp.Diagnostics.AddAttributeError(
+					allowedMethodsPath.WithAttributeName("cached_methods").WithElementKeyInt(i),
+					"Invalid cached method",
+					fmt.Sprintf("cached methods must be a subset of the allowed methods %v, got %q", allowed, method),
+				)
+			}
+		}
+	}
+
+	validateOneAssociationPerEventType(functionAssociations, tftypes.NewAttributePath().WithAttributeName("function_associations"), &resp.Diagnostics)
+	validateOneAssociationPerEventType(lambdaFunctionAssociations, tftypes.NewAttributePath().WithAttributeName("lambda_function_associations"), &resp.Diagnostics)
+
+	if !lambdaFunctionAssociations.IsNull() && !lambdaFunctionAssociations.IsUnknown() {
+		for i, elem := range lambdaFunctionAssociations.Elems {
+			association, ok := elem.(types.Object)
+			if !ok || association.IsNull() || association.IsUnknown() {
+				continue
+			}
+			eventType, _ := association.Attrs["event_type"].(types.String)
+			includeBody, _ := association.Attrs["include_body"].(types.Bool)
+			isResponse := eventType.Value == string(cloudfrontTypes.EventTypeViewerResponse) || eventType.Value == string(cloudfrontTypes.EventTypeOriginResponse)
+			if isResponse && includeBody.Value {
+				resp.Diagnostics.AddAttributeError(
+					tftypes.NewAttributePath().WithAttributeName("lambda_function_associations").WithElementKeyInt(i).WithAttributeName("include_body"),
+					"Invalid include_body",
+					fmt.Sprintf("the body can only be included for request events, got event type %q", eventType.Value),
+				)
+			}
+		}
+	}
+
+	if trustedKeyGroupsEnabled.Value && trustedSignersEnabled.Value {
+		resp.Diagnostics.AddAttributeError(
+			tftypes.NewAttributePath().WithAttributeName("trusted_signers").WithAttributeName("enabled"),
+			"Conflicting trusted signers",
+			"a cache behaviour can either use trusted key groups or trusted signers, enable only one of them",
+		)
+	}
+}
+
+// validateOneAssociationPerEventType adds an error for every association of
+// a list which has the same event type as an earlier one.
+func validateOneAssociationPerEventType(associations types.List, path *tftypes.AttributePath, diags *diag.Diagnostics) {
+	if associations.IsNull() || associations.IsUnknown() {
+		return
+	}
+
+	var seen []string
+	for i, elem := range associations.Elems {
+		association, ok := elem.(types.Object)
+		if !ok || association.IsNull() || association.IsUnknown() {
+			continue
+		}
+		eventType, _ := association.Attrs["event_type"].(types.String)
+		if eventType.IsNull() || eventType.IsUnknown() {
+			continue
+		}
+		if slices.Contains(seen, eventType.Value) {
+			diags.AddAttributeError(
+				path.WithElementKeyInt(i).WithAttributeName("event_type"),
+				"Duplicate event type",
+				fmt.Sprintf("only one association is allowed per event type, %q is used more than once", eventType.Value),
+			)
+		}
+		seen = append(seen, eventType.Value)
+	}
+}
+
+// knownStrings returns the values of a list of strings, it reports false if
+// the list or any of its elements is unknown.
+func knownStrings(list types.List) ([]string, bool) {
+	if list.IsUnknown() {
+		return nil, false
+	}
+
+	var values []string
+	for _, elem := range list.Elems {
+		value, ok := elem.(types.String)
+		if !ok || value.IsUnknown() {
+			return nil, false
+		}
+		values = append(values, value.Value)
+	}
+	return values, true
 }
@@ -2,30 +2,43 @@ package internal
 
 import (
 	"context"
+	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	cloudfrontTypes "github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"golang.org/x/exp/slices"
+	"math"
 )
 
 type CacheBehaviour struct {
+	Id                         types.String                `tfsdk:"id"`
 	DistributionId             types.String                `tfsdk:"distribution_id"`
 	OriginId                   types.String                `tfsdk:"origin_id"`
 	ViewerProtocolPolicy       types.String                `tfsdk:"viewer_protocol_policy"`
 	PathPattern                types.String                `tfsdk:"path_pattern"`
+	Precedence                 types.Int64                 `tfsdk:"precedence"`
 	CachePolicyId              types.String                `tfsdk:"cache_policy_id"`
 	AllowedMethods             *AllowedMethods             `tfsdk:"allowed_methods"`
 	Compress                   types.Bool                  `tfsdk:"compress"`
+	DefaultTTL                 types.Int64                 `tfsdk:"default_ttl"`
 	FieldLevelEncryptionId     types.String                `tfsdk:"field_level_encryption_id"`
+	ForwardedValues            *ForwardedValues            `tfsdk:"forwarded_values"`
 	FunctionAssociations       []FunctionAssociation       `tfsdk:"function_associations"`
 	LambdaFunctionAssociations []LambdaFunctionAssociation `tfsdk:"lambda_function_associations"`
+	MaxTTL                     types.Int64                 `tfsdk:"max_ttl"`
+	MinTTL                     types.Int64                 `tfsdk:"min_ttl"`
 	OriginRequestPolicyId      types.String                `tfsdk:"origin_request_policy_id"`
 	RealtimeLogConfigArn       types.String                `tfsdk:"realtime_log_config_arn"`
 	ResponseHeadersPolicyId    types.String                `tfsdk:"response_headers_policy_id"`
 	SmoothStreaming            types.Bool                  `tfsdk:"smooth_streaming"`
 	TrustedKeyGroups           *TrustedKeyGroups           `tfsdk:"trusted_key_groups"`
 	TrustedSigners             *TrustedSigners             `tfsdk:"trusted_signers"`
+	WaitForDeployment          types.Bool                  `tfsdk:"wait_for_deployment"`
+	Timeouts                   *Timeouts                   `tfsdk:"timeouts"`
+	MoveInPlace                types.Bool                  `tfsdk:"move_in_place"`
 }
 
 type AllowedMethods struct {
@@ -33,6 +46,18 @@ type AllowedMethods struct {
 	CachedMethods []types.String `tfsdk:"cached_methods"`
 }
 
+type ForwardedValues struct {
+	QueryString          types.Bool     `tfsdk:"query_string"`
+	QueryStringCacheKeys []types.String `tfsdk:"query_string_cache_keys"`
+	Headers              []types.String `tfsdk:"headers"`
+	Cookies              *Cookies       `tfsdk:"cookies"`
+}
+
+type Cookies struct {
+	Forward          types.String   `tfsdk:"forward"`
+	WhitelistedNames []types.String `tfsdk:"whitelisted_names"`
+}
+
 type FunctionAssociation struct {
 	EventType types.String `tfsdk:"event_type"`
 	Arn       types.String `tfsdk:"function_arn"`
@@ -54,58 +79,170 @@ type TrustedSigners struct {
 	Signers []types.String `tfsdk:"signers"`
 }
 
+// The TTLs CloudFront uses for cache behaviours with forwarded values which
+// do not configure them.
+const (
+	defaultMinTTL     = 0
+	defaultDefaultTTL = 86400
+	defaultMaxTTL     = 31536000
+)
+
+// The methods CloudFront gets for cache behaviours which do not configure
+// them.
+var (
+	defaultAllowedMethods = []cloudfrontTypes.Method{cloudfrontTypes.MethodHead, cloudfrontTypes.MethodGet, cloudfrontTypes.MethodOptions}
+	defaultCachedMethods  = []cloudfrontTypes.Method{cloudfrontTypes.MethodHead, cloudfrontTypes.MethodGet}
+)
+
 type CacheBehaviourResourceType struct{}
 
 func (o CacheBehaviourResourceType) GetSchema(_ context.Context) (tfsdk.Schema, diag.Diagnostics) {
 	return tfsdk.Schema{
 		Attributes: map[string]tfsdk.Attribute{
+			"id": {
+				Type:     types.StringType,
+				Computed: true,
+			},
 			"distribution_id": {
 				Type:     types.StringType,
 				Required: true,
+				PlanModifiers: tfsdk.AttributePlanModifiers{
+					requiresReplaceUnlessMoveInPlace{},
+				},
 			},
 			"origin_id": {
-				Type:     types.StringType,
-				Required: true,
+				Type:        types.StringType,
+				Required:    true,
+				Description: "The id of the origin the cache behaviour targets. To target an origin managed by a `twilliate_cloudfront_origin` of the same run, reference its `origin_id`, so the origin is created first.",
 			},
 			"viewer_protocol_policy": {
 				Type:     types.StringType,
 				Required: true,
+				Validators: []tfsdk.AttributeValidator{
+					enumValidator(cloudfrontTypes.ViewerProtocolPolicy("").Values()),
+				},
 			},
 			"path_pattern": {
 				Type:     types.StringType,
 				Required: true,
 			},
+			"precedence": {
+				Type:     types.Int64Type,
+				Optional: true,
+				Computed: true,
+				PlanModifiers: tfsdk.AttributePlanModifiers{
+					tfsdk.UseStateForUnknown(),
+				},
+				Validators: []tfsdk.AttributeValidator{
+					int64RangeValidator{min: 0, max: math.MaxInt32},
+				},
+			},
 			"cache_policy_id": {
 				Type:     types.StringType,
-				Required: true,
+				Optional: true,
 			},
 			"allowed_methods": {
 				Optional: true,
+				Computed: true,
+				PlanModifiers: tfsdk.AttributePlanModifiers{
+					defaultValue{value: types.Object{
+						AttrTypes: map[string]attr.Type{
+							"allowed_methods": types.ListType{ElemType: types.StringType},
+							"cached_methods":  types.ListType{ElemType: types.StringType},
+						},
+						Attrs: map[string]attr.Value{
+							"allowed_methods": methodsList(defaultAllowedMethods),
+							"cached_methods":  methodsList(defaultCachedMethods),
+						},
+					}},
+				},
 				Attributes: tfsdk.SingleNestedAttributes(map[string]tfsdk.Attribute{
 					"allowed_methods": {
 						Type:     types.ListType{ElemType: types.StringType},
 						Optional: true,
+						Computed: true,
+						Validators: []tfsdk.AttributeValidator{
+							elementsValidator{validator: enumValidator(cloudfrontTypes.Method("").Values())},
+						},
+						PlanModifiers: tfsdk.AttributePlanModifiers{
+							defaultValue{value: methodsList(defaultAllowedMethods)},
+						},
 					},
 					"cached_methods": {
 						Type:     types.ListType{ElemType: types.StringType},
 						Optional: true,
+						Computed: true,
+						Validators: []tfsdk.AttributeValidator{
+							elementsValidator{validator: enumValidator(cloudfrontTypes.Method("").Values())},
+						},
+						PlanModifiers: tfsdk.AttributePlanModifiers{
+							defaultValue{value: methodsList(defaultCachedMethods)},
+						},
 					},
 				}),
 			},
 			"compress": {
-				Optional: true,
 				Type:     types.BoolType,
+				Optional: true,
+				Computed: true,
+				PlanModifiers: tfsdk.AttributePlanModifiers{
+					defaultValue{value: types.Bool{Value: true}},
+				},
+			},
+			"default_ttl": {
+				Type:     types.Int64Type,
+				Optional: true,
+				Computed: true,
+				Validators: []tfsdk.AttributeValidator{
+					int64RangeValidator{min: 0, max: math.MaxInt64},
+				},
 			},
 			"field_level_encryption_id": {
 				Type:     types.StringType,
 				Optional: true,
 			},
+			"forwarded_values": {
+				Optional: true,
+				Attributes: tfsdk.SingleNestedAttributes(map[string]tfsdk.Attribute{
+					"query_string": {
+						Type:     types.BoolType,
+						Required: true,
+					},
+					"query_string_cache_keys": {
+						Type:     types.ListType{ElemType: types.StringType},
+						Optional: true,
+					},
+					"headers": {
+						Type:     types.ListType{ElemType: types.StringType},
+						Optional: true,
+					},
+					"cookies": {
+						Required: true,
+						Attributes: tfsdk.SingleNestedAttributes(map[string]tfsdk.Attribute{
+							"forward": {
+								Type:     types.StringType,
+								Required: true,
+								Validators: []tfsdk.AttributeValidator{
+									enumValidator(cloudfrontTypes.ItemSelection("").Values()),
+								},
+							},
+							"whitelisted_names": {
+								Type:     types.ListType{ElemType: types.StringType},
+								Optional: true,
+							},
+						}),
+					},
+				}),
+			},
 			"function_associations": {
 				Optional: true,
 				Attributes: tfsdk.ListNestedAttributes(map[string]tfsdk.Attribute{
 					"event_type": {
 						Type:     types.StringType,
 						Required: true,
+						Validators: []tfsdk.AttributeValidator{
+							enumValidator(cloudfrontTypes.EventType("").Values()),
+						},
 					},
 					"function_arn": {
 						Type:     types.StringType,
@@ -119,6 +256,9 @@ func (o CacheBehaviourResourceType) GetSchema(_ context.Context) (tfsdk.Schema,
 					"event_type": {
 						Type:     types.StringType,
 						Required: true,
+						Validators: []tfsdk.AttributeValidator{
+							enumValidator(cloudfrontTypes.EventType("").Values()),
+						},
 					},
 					"function_arn": {
 						Type:     types.StringType,
@@ -127,9 +267,29 @@ func (o CacheBehaviourResourceType) GetSchema(_ context.Context) (tfsdk.Schema,
 					"include_body": {
 						Type:     types.BoolType,
 						Optional: true,
+						Computed: true,
+						PlanModifiers: tfsdk.AttributePlanModifiers{
+							defaultValue{value: types.Bool{Value: false}},
+						},
 					},
 				}),
 			},
+			"max_ttl": {
+				Type:     types.Int64Type,
+				Optional: true,
+				Computed: true,
+				Validators: []tfsdk.AttributeValidator{
+					int64RangeValidator{min: 0, max: math.MaxInt64},
+				},
+			},
+			"min_ttl": {
+				Type:     types.Int64Type,
+				Optional: true,
+				Computed: true,
+				Validators: []tfsdk.AttributeValidator{
+					int64RangeValidator{min: 0, max: math.MaxInt64},
+				},
+			},
 			"origin_request_policy_id": {
 				Type:     types.StringType,
 				Optional: true,
@@ -145,6 +305,10 @@ func (o CacheBehaviourResourceType) GetSchema(_ context.Context) (tfsdk.Schema,
 			"smooth_streaming": {
 				Type:     types.BoolType,
 				Optional: true,
+				Computed: true,
+				PlanModifiers: tfsdk.AttributePlanModifiers{
+					defaultValue{value: types.Bool{Value: false}},
+				},
 			},
 			"trusted_key_groups": {
 				Optional: true,
@@ -152,6 +316,10 @@ func (o CacheBehaviourResourceType) GetSchema(_ context.Context) (tfsdk.Schema,
 					"enabled": {
 						Type:     types.BoolType,
 						Optional: true,
+						Computed: true,
+						PlanModifiers: tfsdk.AttributePlanModifiers{
+							defaultValue{value: types.Bool{Value: false}},
+						},
 					},
 					"groups": {
 						Type:     types.ListType{ElemType: types.StringType},
@@ -165,6 +333,10 @@ func (o CacheBehaviourResourceType) GetSchema(_ context.Context) (tfsdk.Schema,
 					"enabled": {
 						Type:     types.BoolType,
 						Optional: true,
+						Computed: true,
+						PlanModifiers: tfsdk.AttributePlanModifiers{
+							defaultValue{value: types.Bool{Value: false}},
+						},
 					},
 					"signers": {
 						Type:     types.ListType{ElemType: types.StringType},
@@ -172,24 +344,130 @@ func (o CacheBehaviourResourceType) GetSchema(_ context.Context) (tfsdk.Schema,
 					},
 				}),
 			},
+			"wait_for_deployment": {
+				Type:     types.BoolType,
+				Optional: true,
+			},
+			"timeouts": timeoutsAttribute(),
+			"move_in_place": {
+				Type:     types.BoolType,
+				Optional: true,
+			},
 		},
 	}, nil
 }
 
 func (o CacheBehaviourResourceType) NewResource(_ context.Context, p tfsdk.Provider) (tfsdk.Resource, diag.Diagnostics) {
 	return CacheBehaviourResource{
-		client: p.(*provider).client,
+		client:            p.(*provider).client,
+		distributions:     p.(*provider).distributions,
+		origins:           p.(*provider).origins,
+		allowlist:         p.(*provider).allowlist,
+		waitForDeployment: p.(*provider).waitForDeployment,
 	}, nil
 }
 
+// cacheBehaviourId identifies a cache behaviour by its distribution and path
+// pattern, which is unique within a distribution.
+func cacheBehaviourId(distributionId string, pathPattern string) string {
+	return distributionId + "/" + pathPattern
+}
+
+// indexIn returns the index of the cache behaviour with the same path
+// pattern, or -1 if there is none.
+func (c CacheBehaviour) indexIn(behaviours []cloudfrontTypes.CacheBehavior) int {
+	return slices.IndexFunc(behaviours, func(behaviour cloudfrontTypes.CacheBehavior) bool {
+		return aws.ToString(behaviour.PathPattern) == c.PathPattern.Value
+	})
+}
+
+// insertInto inserts the cache behaviour at its precedence, CloudFront
+// evaluates cache behaviours in the order of the list. Without a precedence
+// it is appended. It returns the resulting list and the position of the
+// cache behaviour, a precedence beyond the end of the list is an error.
+func (c CacheBehaviour) insertInto(behaviours []cloudfrontTypes.CacheBehavior) ([]cloudfrontTypes.CacheBehavior, int, error) {
+	idx := len(behaviours)
+	if !c.Precedence.IsNull() && !c.Precedence.IsUnknown() {
+		if c.Precedence.Value > int64(idx) {
+			return behaviours, 0, fmt.Errorf("the precedence %d of cache behaviour %s is beyond the end of the %d other cache behaviours", c.Precedence.Value, c.PathPattern.Value, idx)
+		}
+		idx = int(c.Precedence.Value)
+	}
+	return slices.Insert(behaviours, idx, c.ToCloudfrontCacheBehaviour()), idx, nil
+}
+
+// createMutation adds the cache behaviour to the distribution config and
+// stores its position in precedence.
+func (c CacheBehaviour) createMutation(precedence *int) distributionMutation {
+	return func(distributionConfig *cloudfrontTypes.DistributionConfig) error {
+		items, idx, err := c.insertInto(distributionConfig.CacheBehaviors.Items)
+		if err != nil {
+			return err
+		}
+		distributionConfig.CacheBehaviors.Items, *precedence = items, idx
+		*distributionConfig.CacheBehaviors.Quantity++
+		return nil
+	}
+}
+
+// updateMutation replaces the prior cache behaviour in the distribution
+// config, or adds it if it has been moved from another distribution. Without
+// a known precedence it keeps its current position, which other cache
+// behaviours inserted in the same batch might have shifted. It stores the
+// resulting position in precedence.
+func (c CacheBehaviour) updateMutation(prior CacheBehaviour, moved bool, precedence *int) distributionMutation {
+	return func(distributionConfig *cloudfrontTypes.DistributionConfig) error {
+		behaviour := c
+		idx := -1
+		if !moved {
+			idx = prior.indexIn(distributionConfig.CacheBehaviors.Items)
+		}
+
+		if (moved || c.PathPattern.Value != prior.PathPattern.Value) && c.indexIn(distributionConfig.CacheBehaviors.Items) != -1 {
+			return fmt.Errorf("the distribution %s already has a cache behaviour with path %s", c.DistributionId.Value, c.PathPattern.Value)
+		}
+
+		items := distributionConfig.CacheBehaviors.Items
+		if idx != -1 {
+			// move the cache behaviour if its precedence is set, otherwise keep its position
+			items = slices.Delete(slices.Clone(items), idx, idx+1)
+			if behaviour.Precedence.IsNull() || behaviour.Precedence.IsUnknown() {
+				behaviour.Precedence = types.Int64{Value: int64(idx)}
+			}
+		}
+
+		items, inserted, err := behaviour.insertInto(items)
+		if err != nil {
+			return err
+		}
+
+		if idx == -1 {
+			*distributionConfig.CacheBehaviors.Quantity++
+		}
+		distributionConfig.CacheBehaviors.Items, *precedence = items, inserted
+		return nil
+	}
+}
+
+// insertOrder returns the order in which the cache behaviour is inserted
+// within a batch of updates. Cache behaviours are inserted by ascending
+// precedence, so an insertion does not shift cache behaviours which have
+// been inserted before, and appended ones come last.
+func (c CacheBehaviour) insertOrder() int64 {
+	if c.Precedence.IsNull() || c.Precedence.IsUnknown() {
+		return math.MaxInt64
+	}
+	return c.Precedence.Value
+}
+
 func (c CacheBehaviour) ToCloudfrontAllowedMethods() *cloudfrontTypes.AllowedMethods {
 	if c.AllowedMethods == nil || len(c.AllowedMethods.Items) == 0 {
 		return &cloudfrontTypes.AllowedMethods{
-			Items:    []cloudfrontTypes.Method{"HEAD", "GET", "OPTIONS"},
-			Quantity: aws.Int32(3),
+			Items:    slices.Clone(defaultAllowedMethods),
+			Quantity: aws.Int32(int32(len(defaultAllowedMethods))),
 			CachedMethods: &cloudfrontTypes.CachedMethods{
-				Items:    []cloudfrontTypes.Method{"HEAD", "GET"},
-				Quantity: aws.Int32(2),
+				Items:    slices.Clone(defaultCachedMethods),
+				Quantity: aws.Int32(int32(len(defaultCachedMethods))),
 			},
 		}
 	}
@@ -204,7 +482,8 @@ func (c CacheBehaviour) ToCloudfrontAllowedMethods() *cloudfrontTypes.AllowedMet
 			Items:    items,
 			Quantity: aws.Int32(int32(len(c.AllowedMethods.Items))),
 			CachedMethods: &cloudfrontTypes.CachedMethods{
-				Quantity: aws.Int32(0),
+				Items:    slices.Clone(defaultCachedMethods),
+				Quantity: aws.Int32(int32(len(defaultCachedMethods))),
 			},
 		}
 	}
@@ -307,17 +586,97 @@ func (c CacheBehaviour) ToTrustedSigners() *cloudfrontTypes.TrustedSigners {
 	}
 }
 
+// withDefaultTTLs resolves the TTLs which have been planned as unknown,
+// because they default to configured TTLs which were not known yet.
+func (c CacheBehaviour) withDefaultTTLs() CacheBehaviour {
+	if c.ForwardedValues == nil {
+		c.MinTTL, c.DefaultTTL, c.MaxTTL = types.Int64{Null: true}, types.Int64{Null: true}, types.Int64{Null: true}
+		return c
+	}
+
+	for _, ttl := range []*types.Int64{&c.MinTTL, &c.DefaultTTL, &c.MaxTTL} {
+		if ttl.IsUnknown() {
+			*ttl = types.Int64{Null: true}
+		}
+	}
+	c.MinTTL, c.DefaultTTL, c.MaxTTL = defaultTTLs(c.MinTTL, c.DefaultTTL, c.MaxTTL)
+	return c
+}
+
+// defaultTTLs replaces null TTLs with the defaults of CloudFront, which
+// raises the default and maximum TTL to the TTL below them. A default
+// depending on an unknown TTL is unknown.
+func defaultTTLs(minTTL types.Int64, defaultTTL types.Int64, maxTTL types.Int64) (types.Int64, types.Int64, types.Int64) {
+	if minTTL.IsNull() {
+		minTTL = types.Int64{Value: defaultMinTTL}
+	}
+
+	if defaultTTL.IsNull() && minTTL.IsUnknown() {
+		defaultTTL = types.Int64{Unknown: true}
+	} else if defaultTTL.IsNull() {
+		defaultTTL = types.Int64{Value: defaultDefaultTTL}
+		if minTTL.Value > defaultTTL.Value {
+			defaultTTL.Value = minTTL.Value
+		}
+	}
+
+	if maxTTL.IsNull() && defaultTTL.IsUnknown() {
+		maxTTL = types.Int64{Unknown: true}
+	} else if maxTTL.IsNull() {
+		maxTTL = types.Int64{Value: defaultMaxTTL}
+		if defaultTTL.Value > maxTTL.Value {
+			maxTTL.Value = defaultTTL.Value
+		}
+	}
+
+	return minTTL, defaultTTL, maxTTL
+}
+
+func (c CacheBehaviour) ToForwardedValues() *cloudfrontTypes.ForwardedValues {
+	if c.ForwardedValues == nil {
+		return nil
+	}
+
+	forwardedValues := &cloudfrontTypes.ForwardedValues{
+		QueryString: aws.Bool(c.ForwardedValues.QueryString.Value),
+		QueryStringCacheKeys: &cloudfrontTypes.QueryStringCacheKeys{
+			Quantity: aws.Int32(int32(len(c.ForwardedValues.QueryStringCacheKeys))),
+			Items:    toStrings(c.ForwardedValues.QueryStringCacheKeys),
+		},
+		Headers: &cloudfrontTypes.Headers{
+			Quantity: aws.Int32(int32(len(c.ForwardedValues.Headers))),
+			Items:    toStrings(c.ForwardedValues.Headers),
+		},
+	}
+
+	if c.ForwardedValues.Cookies != nil {
+		forwardedValues.Cookies = &cloudfrontTypes.CookiePreference{
+			Forward: cloudfrontTypes.ItemSelection(c.ForwardedValues.Cookies.Forward.Value),
+			WhitelistedNames: &cloudfrontTypes.CookieNames{
+				Quantity: aws.Int32(int32(len(c.ForwardedValues.Cookies.WhitelistedNames))),
+				Items:    toStrings(c.ForwardedValues.Cookies.WhitelistedNames),
+			},
+		}
+	}
+
+	return forwardedValues
+}
+
 func (c CacheBehaviour) ToCloudfrontCacheBehaviour() cloudfrontTypes.CacheBehavior {
 	return cloudfrontTypes.CacheBehavior{
 		PathPattern:                aws.String(c.PathPattern.Value),
 		TargetOriginId:             aws.String(c.OriginId.Value),
 		ViewerProtocolPolicy:       cloudfrontTypes.ViewerProtocolPolicy(c.ViewerProtocolPolicy.Value),
 		AllowedMethods:             c.ToCloudfrontAllowedMethods(),
-		CachePolicyId:              aws.String(c.CachePolicyId.Value),
+		CachePolicyId:              toStringOrNil(c.CachePolicyId),
 		Compress:                   toBool(c.Compress, true),
+		DefaultTTL:                 toInt64(c.DefaultTTL),
 		FieldLevelEncryptionId:     toString(c.FieldLevelEncryptionId),
+		ForwardedValues:            c.ToForwardedValues(),
 		FunctionAssociations:       c.ToFunctionAssociation(),
 		LambdaFunctionAssociations: c.ToLambdaFunctionAssociation(),
+		MaxTTL:                     toInt64(c.MaxTTL),
+		MinTTL:                     toInt64(c.MinTTL),
 		OriginRequestPolicyId:      toStringOrNil(c.OriginRequestPolicyId),
 		RealtimeLogConfigArn:       toStringOrNil(c.RealtimeLogConfigArn),
 		ResponseHeadersPolicyId:    toStringOrNil(c.ResponseHeadersPolicyId),
@@ -326,3 +685,173 @@ func (c CacheBehaviour) ToCloudfrontCacheBehaviour() cloudfrontTypes.CacheBehavi
 		TrustedSigners:             c.ToTrustedSigners(),
 	}
 }
+
+func fromCloudfrontMethods(methods []cloudfrontTypes.Method) []types.String {
+	var items []types.String
+	for _, method := range methods {
+		items = append(items, types.String{Value: string(method)})
+	}
+	return items
+}
+
+// methodsList converts methods into a list value for plan modifiers.
+func methodsList(methods []cloudfrontTypes.Method) types.List {
+	var elems []attr.Value
+	for _, method := range fromCloudfrontMethods(methods) {
+		elems = append(elems, method)
+	}
+	return types.List{ElemType: types.StringType, Elems: elems}
+}
+
+// CacheBehaviourFromCloudfront converts a cache behaviour of a distribution
+// config back into the resource model, precedence is its position in the
+// list of cache behaviours.
+func CacheBehaviourFromCloudfront(distributionId string, precedence int, behaviour cloudfrontTypes.CacheBehavior) CacheBehaviour {
+	var allowedMethods *AllowedMethods
+	if behaviour.AllowedMethods != nil && len(behaviour.AllowedMethods.Items) > 0 {
+		allowedMethods = &AllowedMethods{
+			Items: fromCloudfrontMethods(behaviour.AllowedMethods.Items),
+		}
+		if behaviour.AllowedMethods.CachedMethods != nil {
+			allowedMethods.CachedMethods = fromCloudfrontMethods(behaviour.AllowedMethods.CachedMethods.Items)
+		}
+	}
+
+	// the TTLs of a cache behaviour only apply to forwarded values
+	var forwardedValues *ForwardedValues
+	minTTL, defaultTTL, maxTTL := types.Int64{Null: true}, types.Int64{Null: true}, types.Int64{Null: true}
+	if behaviour.ForwardedValues != nil {
+		forwardedValues = &ForwardedValues{
+			QueryString: types.Bool{Value: aws.ToBool(behaviour.ForwardedValues.QueryString)},
+		}
+		if behaviour.ForwardedValues.QueryStringCacheKeys != nil {
+			forwardedValues.QueryStringCacheKeys = fromStrings(behaviour.ForwardedValues.QueryStringCacheKeys.Items)
+		}
+		if behaviour.ForwardedValues.Headers != nil {
+			forwardedValues.Headers = fromStrings(behaviour.ForwardedValues.Headers.Items)
+		}
+		if behaviour.ForwardedValues.Cookies != nil {
+			forwardedValues.Cookies = &Cookies{
+				Forward: types.String{Value: string(behaviour.ForwardedValues.Cookies.Forward)},
+			}
+			if behaviour.ForwardedValues.Cookies.WhitelistedNames != nil {
+				forwardedValues.Cookies.WhitelistedNames = fromStrings(behaviour.ForwardedValues.Cookies.WhitelistedNames.Items)
+			}
+		}
+		minTTL, defaultTTL, maxTTL = fromInt64(behaviour.MinTTL), fromInt64(behaviour.DefaultTTL), fromInt64(behaviour.MaxTTL)
+	}
+
+	var functionAssociations []FunctionAssociation
+	if behaviour.FunctionAssociations != nil {
+		for _, function := range behaviour.FunctionAssociations.Items {
+			functionAssociations = append(functionAssociations, FunctionAssociation{
+				EventType: types.String{Value: string(function.EventType)},
+				Arn:       types.String{Value: aws.ToString(function.FunctionARN)},
+			})
+		}
+	}
+
+	var lambdaFunctionAssociations []LambdaFunctionAssociation
+	if behaviour.LambdaFunctionAssociations != nil {
+		for _, function := range behaviour.LambdaFunctionAssociations.Items {
+			lambdaFunctionAssociations = append(lambdaFunctionAssociations, LambdaFunctionAssociation{
+				EventType:   types.String{Value: string(function.EventType)},
+				Arn:         types.String{Value: aws.ToString(function.LambdaFunctionARN)},
+				IncludeBody: fromBool(function.IncludeBody),
+			})
+		}
+	}
+
+	var trustedKeyGroups *TrustedKeyGroups
+	if behaviour.TrustedKeyGroups != nil && len(behaviour.TrustedKeyGroups.Items) > 0 {
+		trustedKeyGroups = &TrustedKeyGroups{
+			Enabled: fromBool(behaviour.TrustedKeyGroups.Enabled),
+			Groups:  fromStrings(behaviour.TrustedKeyGroups.Items),
+		}
+	}
+
+	var trustedSigners *TrustedSigners
+	if behaviour.TrustedSigners != nil && len(behaviour.TrustedSigners.Items) > 0 {
+		trustedSigners = &TrustedSigners{
+			Enabled: fromBool(behaviour.TrustedSigners.Enabled),
+			Signers: fromStrings(behaviour.TrustedSigners.Items),
+		}
+	}
+
+	return CacheBehaviour{
+		Id:                         types.String{Value: cacheBehaviourId(distributionId, aws.ToString(behaviour.PathPattern))},
+		DistributionId:             types.String{Value: distributionId},
+		OriginId:                   types.String{Value: aws.ToString(behaviour.TargetOriginId)},
+		ViewerProtocolPolicy:       types.String{Value: string(behaviour.ViewerProtocolPolicy)},
+		PathPattern:                types.String{Value: aws.ToString(behaviour.PathPattern)},
+		Precedence:                 types.Int64{Value: int64(precedence)},
+		CachePolicyId:              fromString(behaviour.CachePolicyId),
+		AllowedMethods:             allowedMethods,
+		Compress:                   fromBool(behaviour.Compress),
+		DefaultTTL:                 defaultTTL,
+		FieldLevelEncryptionId:     fromString(behaviour.FieldLevelEncryptionId),
+		ForwardedValues:            forwardedValues,
+		FunctionAssociations:       functionAssociations,
+		LambdaFunctionAssociations: lambdaFunctionAssociations,
+		MaxTTL:                     maxTTL,
+		MinTTL:                     minTTL,
+		OriginRequestPolicyId:      fromString(behaviour.OriginRequestPolicyId),
+		RealtimeLogConfigArn:       fromString(behaviour.RealtimeLogConfigArn),
+		ResponseHeadersPolicyId:    fromString(behaviour.ResponseHeadersPolicyId),
+		SmoothStreaming:            fromBool(behaviour.SmoothStreaming),
+		TrustedKeyGroups:           trustedKeyGroups,
+		TrustedSigners:             trustedSigners,
+		WaitForDeployment:          types.Bool{Null: true},
+		MoveInPlace:                types.Bool{Null: true},
+	}
+}
+
+// keepUnsetDefaults keeps the prior form of settings which CloudFront
+// reports differently than they have been configured, so they do not show
+// up as drift.
+func (c CacheBehaviour) keepUnsetDefaults(prior CacheBehaviour) CacheBehaviour {
+	c.FieldLevelEncryptionId = keepEmptyString(prior.FieldLevelEncryptionId, c.FieldLevelEncryptionId)
+
+	// without prior methods, e.g. after an import, keep the order of the defaults
+	priorAllowedMethods := &AllowedMethods{
+		Items:         fromCloudfrontMethods(defaultAllowedMethods),
+		CachedMethods: fromCloudfrontMethods(defaultCachedMethods),
+	}
+	if prior.AllowedMethods != nil {
+		priorAllowedMethods = prior.AllowedMethods
+	}
+	if c.AllowedMethods != nil {
+		c.AllowedMethods.Items = keepOrder(priorAllowedMethods.Items, c.AllowedMethods.Items)
+		c.AllowedMethods.CachedMethods = keepOrder(priorAllowedMethods.CachedMethods, c.AllowedMethods.CachedMethods)
+	}
+
+	if c.ForwardedValues != nil && prior.ForwardedValues != nil {
+		c.ForwardedValues.QueryStringCacheKeys = keepOrder(prior.ForwardedValues.QueryStringCacheKeys, c.ForwardedValues.QueryStringCacheKeys)
+		c.ForwardedValues.Headers = keepOrder(prior.ForwardedValues.Headers, c.ForwardedValues.Headers)
+		if c.ForwardedValues.Cookies != nil && prior.ForwardedValues.Cookies != nil {
+			c.ForwardedValues.Cookies.WhitelistedNames = keepOrder(prior.ForwardedValues.Cookies.WhitelistedNames, c.ForwardedValues.Cookies.WhitelistedNames)
+		}
+	}
+
+	if c.FunctionAssociations == nil && prior.FunctionAssociations != nil && len(prior.FunctionAssociations) == 0 {
+		c.FunctionAssociations = prior.FunctionAssociations
+	}
+
+	if c.LambdaFunctionAssociations == nil && prior.LambdaFunctionAssociations != nil && len(prior.LambdaFunctionAssociations) == 0 {
+		c.LambdaFunctionAssociations = prior.LambdaFunctionAssociations
+	}
+
+	if prior.TrustedKeyGroups != nil && c.TrustedKeyGroups == nil && len(prior.TrustedKeyGroups.Groups) == 0 {
+		c.TrustedKeyGroups = prior.TrustedKeyGroups
+	} else if prior.TrustedKeyGroups != nil && c.TrustedKeyGroups != nil {
+		c.TrustedKeyGroups.Groups = keepOrder(prior.TrustedKeyGroups.Groups, c.TrustedKeyGroups.Groups)
+	}
+
+	if prior.TrustedSigners != nil && c.TrustedSigners == nil && len(prior.TrustedSigners.Signers) == 0 {
+		c.TrustedSigners = prior.TrustedSigners
+	} else if prior.TrustedSigners != nil && c.TrustedSigners != nil {
+		c.TrustedSigners.Signers = keepOrder(prior.TrustedSigners.Signers, c.TrustedSigners.Signers)
+	}
+
+	return c
+}
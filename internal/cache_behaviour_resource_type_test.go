@@ -0,0 +1,194 @@
+package internal
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cloudfrontTypes "github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"reflect"
+	"testing"
+)
+
+// plannedCacheBehaviour returns a cache behaviour as it is planned with the
+// defaults of the schema.
+func plannedCacheBehaviour() CacheBehaviour {
+	return CacheBehaviour{
+		Id:                   types.String{Value: "E1/images/*"},
+		DistributionId:       types.String{Value: "E1"},
+		OriginId:             types.String{Value: "origin"},
+		ViewerProtocolPolicy: types.String{Value: "redirect-to-https"},
+		PathPattern:          types.String{Value: "images/*"},
+		Precedence:           types.Int64{Value: 2},
+		CachePolicyId:        types.String{Value: "policy"},
+		AllowedMethods: &AllowedMethods{
+			Items:         fromCloudfrontMethods(defaultAllowedMethods),
+			CachedMethods: fromCloudfrontMethods(defaultCachedMethods),
+		},
+		Compress:                types.Bool{Value: true},
+		DefaultTTL:              types.Int64{Null: true},
+		FieldLevelEncryptionId:  types.String{Null: true},
+		MaxTTL:                  types.Int64{Null: true},
+		MinTTL:                  types.Int64{Null: true},
+		OriginRequestPolicyId:   types.String{Null: true},
+		RealtimeLogConfigArn:    types.String{Null: true},
+		ResponseHeadersPolicyId: types.String{Null: true},
+		SmoothStreaming:         types.Bool{Value: false},
+		WaitForDeployment:       types.Bool{Null: true},
+		MoveInPlace:             types.Bool{Null: true},
+	}
+}
+
+func TestCacheBehaviourRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		plan   func(behaviour *CacheBehaviour)
+		remote func(behaviour *cloudfrontTypes.CacheBehavior)
+	}{
+		{
+			name: "cache policy with default methods",
+			plan: func(behaviour *CacheBehaviour) {},
+		},
+		{
+			name: "policies and options",
+			plan: func(behaviour *CacheBehaviour) {
+				behaviour.Compress = types.Bool{Value: false}
+				behaviour.SmoothStreaming = types.Bool{Value: true}
+				behaviour.FieldLevelEncryptionId = types.String{Value: "encryption"}
+				behaviour.OriginRequestPolicyId = types.String{Value: "origin-request"}
+				behaviour.RealtimeLogConfigArn = types.String{Value: "arn:aws:cloudfront::1:realtime-log-config/logs"}
+				behaviour.ResponseHeadersPolicyId = types.String{Value: "response-headers"}
+			},
+		},
+		{
+			name: "empty field level encryption id",
+			plan: func(behaviour *CacheBehaviour) {
+				behaviour.FieldLevelEncryptionId = types.String{Value: ""}
+			},
+		},
+		{
+			name: "forwarded values with default TTLs",
+			plan: func(behaviour *CacheBehaviour) {
+				behaviour.CachePolicyId = types.String{Null: true}
+				behaviour.ForwardedValues = &ForwardedValues{
+					QueryString: types.Bool{Value: false},
+					Cookies:     &Cookies{Forward: types.String{Value: "none"}},
+				}
+				*behaviour = behaviour.withDefaultTTLs()
+			},
+		},
+		{
+			name: "forwarded values with TTLs and lists",
+			plan: func(behaviour *CacheBehaviour) {
+				behaviour.CachePolicyId = types.String{Null: true}
+				behaviour.ForwardedValues = &ForwardedValues{
+					QueryString:          types.Bool{Value: true},
+					QueryStringCacheKeys: fromStrings([]string{"page", "lang"}),
+					Headers:              fromStrings([]string{"Origin", "Accept"}),
+					Cookies: &Cookies{
+						Forward:          types.String{Value: "whitelist"},
+						WhitelistedNames: fromStrings([]string{"session", "theme"}),
+					},
+				}
+				behaviour.MinTTL = types.Int64{Value: 10}
+				behaviour.DefaultTTL = types.Int64{Value: 60}
+				behaviour.MaxTTL = types.Int64{Value: 3600}
+			},
+			remote: func(behaviour *cloudfrontTypes.CacheBehavior) {
+				// CloudFront does not keep the order of these lists
+				behaviour.ForwardedValues.QueryStringCacheKeys.Items = []string{"lang", "page"}
+				behaviour.ForwardedValues.Headers.Items = []string{"Accept", "Origin"}
+				behaviour.ForwardedValues.Cookies.WhitelistedNames.Items = []string{"theme", "session"}
+			},
+		},
+		{
+			name: "allowed methods in a different order",
+			plan: func(behaviour *CacheBehaviour) {
+				behaviour.AllowedMethods = &AllowedMethods{
+					Items:         fromStrings([]string{"GET", "HEAD", "OPTIONS", "PUT", "POST", "PATCH", "DELETE"}),
+					CachedMethods: fromStrings([]string{"GET", "HEAD", "OPTIONS"}),
+				}
+			},
+			remote: func(behaviour *cloudfrontTypes.CacheBehavior) {
+				behaviour.AllowedMethods.Items = []cloudfrontTypes.Method{"HEAD", "DELETE", "POST", "GET", "OPTIONS", "PUT", "PATCH"}
+				behaviour.AllowedMethods.CachedMethods.Items = []cloudfrontTypes.Method{"HEAD", "GET", "OPTIONS"}
+			},
+		},
+		{
+			name: "empty lists",
+			plan: func(behaviour *CacheBehaviour) {
+				behaviour.FunctionAssociations = []FunctionAssociation{}
+				behaviour.LambdaFunctionAssociations = []LambdaFunctionAssociation{}
+				behaviour.TrustedKeyGroups = &TrustedKeyGroups{Enabled: types.Bool{Value: false}, Groups: []types.String{}}
+				behaviour.TrustedSigners = &TrustedSigners{Enabled: types.Bool{Value: false}, Signers: []types.String{}}
+			},
+		},
+		{
+			name: "function associations",
+			plan: func(behaviour *CacheBehaviour) {
+				behaviour.FunctionAssociations = []FunctionAssociation{
+					{EventType: types.String{Value: "viewer-request"}, Arn: types.String{Value: "arn:aws:cloudfront::1:function/rewrite"}},
+				}
+				behaviour.LambdaFunctionAssociations = []LambdaFunctionAssociation{
+					{EventType: types.String{Value: "origin-request"}, Arn: types.String{Value: "arn:aws:lambda:us-east-1:1:function:auth:1"}, IncludeBody: types.Bool{Value: true}},
+				}
+			},
+		},
+		{
+			name: "trusted key groups",
+			plan: func(behaviour *CacheBehaviour) {
+				behaviour.TrustedKeyGroups = &TrustedKeyGroups{Enabled: types.Bool{Value: true}, Groups: fromStrings([]string{"group-1", "group-2"})}
+			},
+			remote: func(behaviour *cloudfrontTypes.CacheBehavior) {
+				behaviour.TrustedKeyGroups.Items = []string{"group-2", "group-1"}
+			},
+		},
+		{
+			name: "trusted signers",
+			plan: func(behaviour *CacheBehaviour) {
+				behaviour.TrustedSigners = &TrustedSigners{Enabled: types.Bool{Value: true}, Signers: fromStrings([]string{"self", "123456789012"})}
+			},
+			remote: func(behaviour *cloudfrontTypes.CacheBehavior) {
+				behaviour.TrustedSigners.Items = []string{"123456789012", "self"}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			plan := plannedCacheBehaviour()
+			test.plan(&plan)
+
+			remote := plan.ToCloudfrontCacheBehaviour()
+			if test.remote != nil {
+				test.remote(&remote)
+			}
+
+			refreshed := CacheBehaviourFromCloudfront("E1", 2, remote).keepUnsetDefaults(plan)
+			if !reflect.DeepEqual(refreshed, plan) {
+				t.Errorf("expected the refreshed cache behaviour to match the plan\nplan:      %+v\nrefreshed: %+v", plan, refreshed)
+			}
+		})
+	}
+}
+
+func TestCacheBehaviourInjectedDefaults(t *testing.T) {
+	plan := plannedCacheBehaviour()
+	plan.AllowedMethods = nil
+	plan.Compress = types.Bool{Null: true}
+	plan.SmoothStreaming = types.Bool{Null: true}
+
+	remote := plan.ToCloudfrontCacheBehaviour()
+	if !aws.ToBool(remote.Compress) || aws.ToBool(remote.SmoothStreaming) {
+		t.Errorf("expected compress and no smooth streaming by default, got %v and %v", aws.ToBool(remote.Compress), aws.ToBool(remote.SmoothStreaming))
+	}
+	if remote.ForwardedValues != nil || remote.MinTTL != nil || remote.DefaultTTL != nil || remote.MaxTTL != nil {
+		t.Errorf("expected no forwarded values or TTLs with a cache policy")
+	}
+
+	// an imported cache behaviour has no prior settings
+	imported := CacheBehaviour{FieldLevelEncryptionId: types.String{Null: true}}
+	refreshed := CacheBehaviourFromCloudfront("E1", 2, remote).keepUnsetDefaults(imported)
+	expected := plannedCacheBehaviour()
+	if !reflect.DeepEqual(refreshed, expected) {
+		t.Errorf("expected the injected defaults to be read back\nexpected:  %+v\nrefreshed: %+v", expected, refreshed)
+	}
+}
@@ -0,0 +1,370 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	"golang.org/x/exp/slices"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxUpdateAttempts = 5
+	minUpdateBackoff         = 1 * time.Second
+	maxUpdateBackoff         = 30 * time.Second
+	batchWindow              = 500 * time.Millisecond
+	minDeploymentPoll        = 5 * time.Second
+	maxDeploymentPoll        = 60 * time.Second
+	rollbackTimeout          = 2 * time.Minute
+)
+
+// errUnchanged is returned by a mutation if the distribution config does not
+// need to be updated.
+var errUnchanged = errors.New("distribution config is unchanged")
+
+// distributionMutation changes a distribution config in place. It must not
+// modify the config if it returns an error.
+type distributionMutation func(config *types.DistributionConfig) error
+
+// distributionClient is the part of the CloudFront API the updater uses.
+type distributionClient interface {
+	GetDistribution(ctx context.Context, params *cloudfront.GetDistributionInput, optFns ...func(*cloudfront.Options)) (*cloudfront.GetDistributionOutput, error)
+	GetDistributionConfig(ctx context.Context, params *cloudfront.GetDistributionConfigInput, optFns ...func(*cloudfront.Options)) (*cloudfront.GetDistributionConfigOutput, error)
+	UpdateDistribution(ctx context.Context, params *cloudfront.UpdateDistributionInput, optFns ...func(*cloudfront.Options)) (*cloudfront.UpdateDistributionOutput, error)
+}
+
+// distributionUpdater performs the read-modify-write cycle of distribution
+// configs, which all resources of this provider patch. Terraform applies
+// resources in parallel, so mutations of the same distribution are queued
+// and the pending ones are coalesced into a single update.
+type distributionUpdater struct {
+	client      distributionClient
+	maxAttempts int
+	allowlist   *distributionAllowlist
+
+	mutex  sync.Mutex
+	queues map[string][]*pendingMutation
+}
+
+type pendingMutation struct {
+	ctx    context.Context
+	order  int64
+	mutate distributionMutation
+	done   chan error
+
+	// guarded by the mutex of the updater, a mutation can only be withdrawn
+	// while it is not part of an update in flight
+	inFlight  bool
+	withdrawn bool
+}
+
+// rejectedUpdate is an error of CloudFront rejecting an update for a reason
+// other than a changed ETag, e.g. an invalid config.
+type rejectedUpdate struct {
+	err error
+}
+
+func (r rejectedUpdate) Error() string {
+	return r.err.Error()
+}
+
+func (r rejectedUpdate) Unwrap() error {
+	return r.err
+}
+
+func newDistributionUpdater(client distributionClient, maxAttempts int, allowlist *distributionAllowlist) *distributionUpdater {
+	return &distributionUpdater{
+		client:      client,
+		maxAttempts: maxAttempts,
+		allowlist:   allowlist,
+		queues:      map[string][]*pendingMutation{},
+	}
+}
+
+// update queues the mutation for the distribution and waits until it has
+// been written back together with all other mutations pending at that time.
+// If the context is done before the mutation is written, it is withdrawn and
+// the error of the context is returned.
+func (d *distributionUpdater) update(ctx context.Context, distributionId string, mutate distributionMutation) error {
+	return d.updateInOrder(ctx, distributionId, math.MinInt64, mutate)
+}
+
+// updateInOrder is update for mutations which depend on their order within
+// a batch, the mutations of a batch are applied by ascending order.
+func (d *distributionUpdater) updateInOrder(ctx context.Context, distributionId string, order int64, mutate distributionMutation) error {
+	mutation := &pendingMutation{
+		ctx:    ctx,
+		order:  order,
+		mutate: mutate,
+		done:   make(chan error, 1),
+	}
+
+	d.mutex.Lock()
+	pending, running := d.queues[distributionId]
+	d.queues[distributionId] = append(pending, mutation)
+	if !running {
+		go d.process(distributionId)
+	}
+	d.mutex.Unlock()
+
+	select {
+	case err := <-mutation.done:
+		return err
+	case <-ctx.Done():
+	}
+
+	d.mutex.Lock()
+	select {
+	case err := <-mutation.done:
+		d.mutex.Unlock()
+		return err
+	default:
+	}
+	if !mutation.inFlight {
+		mutation.withdrawn = true
+		d.mutex.Unlock()
+		return ctx.Err()
+	}
+	d.mutex.Unlock()
+
+	// the mutation might be written by the update in flight
+	return <-mutation.done
+}
+
+// process applies batches of pending mutations until the queue of the
+// distribution is empty.
+func (d *distributionUpdater) process(distributionId string) {
+	for {
+		// give concurrent operations the chance to join the batch
+		time.Sleep(batchWindow)
+
+		d.mutex.Lock()
+		batch := d.queues[distributionId]
+		if len(batch) == 0 {
+			delete(d.queues, distributionId)
+			d.mutex.Unlock()
+			return
+		}
+		d.queues[distributionId] = nil
+		d.mutex.Unlock()
+
+		slices.SortStableFunc(batch, func(a *pendingMutation, b *pendingMutation) bool {
+			return a.order < b.order
+		})
+		d.apply(distributionId, batch)
+	}
+}
+
+// apply writes the mutations of the batch and reports the outcome to each of
+// them. If CloudFront rejects the combined update, the mutations are written
+// one at a time, so only the mutation causing the rejection fails.
+func (d *distributionUpdater) apply(distributionId string, batch []*pendingMutation) {
+	ctx, cancel := batchContext(batch)
+	defer cancel()
+
+	claimed, results, err := d.write(ctx, distributionId, batch)
+
+	var rejected rejectedUpdate
+	if errors.As(err, &rejected) && len(claimed) > 1 {
+		d.release(claimed)
+		for _, mutation := range claimed {
+			d.apply(distributionId, []*pendingMutation{mutation})
+		}
+		return
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for i, mutation := range claimed {
+		if err != nil {
+			mutation.done <- err
+		} else {
+			mutation.done <- results[i]
+		}
+		mutation.inFlight = false
+	}
+}
+
+// write fetches the config of a distribution, applies the mutations of the
+// batch and writes it back. If the distribution has been changed
+// concurrently, the ETag no longer matches and the whole cycle is retried
+// with backoff on the latest config. It returns the mutations which took
+// part in the last attempt together with their errors.
+func (d *distributionUpdater) write(ctx context.Context, distributionId string, batch []*pendingMutation) ([]*pendingMutation, []error, error) {
+	batch = d.claim(batch)
+	if len(batch) == 0 {
+		return nil, nil, nil
+	}
+
+	// the plan might have been created with a different allowlist
+	if err := d.allowlist.check(ctx, distributionId); err != nil {
+		return batch, nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		out, err := d.client.GetDistributionConfig(ctx, &cloudfront.GetDistributionConfigInput{
+			Id: aws.String(distributionId),
+		})
+
+		if err != nil {
+			return batch, nil, err
+		}
+
+		results := make([]error, len(batch))
+		changed := false
+		for i, mutation := range batch {
+			results[i] = mutation.mutate(out.DistributionConfig)
+			if errors.Is(results[i], errUnchanged) {
+				results[i] = nil
+			} else if results[i] == nil {
+				changed = true
+			}
+		}
+
+		if !changed {
+			return batch, results, nil
+		}
+
+		_, err = d.client.UpdateDistribution(ctx, &cloudfront.UpdateDistributionInput{
+			DistributionConfig: out.DistributionConfig,
+			Id:                 aws.String(distributionId),
+			IfMatch:            out.ETag,
+		})
+
+		if err == nil {
+			return batch, results, nil
+		}
+
+		if !isPreconditionFailed(err) {
+			if ctx.Err() == nil {
+				err = rejectedUpdate{err: err}
+			}
+			return batch, nil, err
+		}
+
+		if attempt >= d.maxAttempts {
+			return batch, nil, err
+		}
+
+		// nothing has been written, so the mutations can be withdrawn until
+		// the next attempt
+		d.release(batch)
+		if err := sleep(ctx, backoff(attempt, minUpdateBackoff, maxUpdateBackoff)); err != nil {
+			return d.claim(batch), nil, err
+		}
+
+		batch = d.claim(batch)
+		if len(batch) == 0 {
+			return nil, nil, nil
+		}
+	}
+}
+
+// claim marks the mutations of the batch as in flight and returns them.
+// Mutations which have been withdrawn or whose context is done are skipped,
+// the latter are answered with the error of their context.
+func (d *distributionUpdater) claim(batch []*pendingMutation) []*pendingMutation {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	var claimed []*pendingMutation
+	for _, mutation := range batch {
+		if !mutation.withdrawn && mutation.ctx.Err() != nil {
+			mutation.withdrawn = true
+			mutation.done <- mutation.ctx.Err()
+		}
+		if mutation.withdrawn {
+			continue
+		}
+		mutation.inFlight = true
+		claimed = append(claimed, mutation)
+	}
+	return claimed
+}
+
+// release allows the mutations of the batch to be withdrawn again.
+func (d *distributionUpdater) release(batch []*pendingMutation) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for _, mutation := range batch {
+		mutation.inFlight = false
+	}
+}
+
+// batchContext returns a context which is cancelled once the contexts of all
+// operations in the batch are done.
+func batchContext(batch []*pendingMutation) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		for _, mutation := range batch {
+			select {
+			case <-mutation.ctx.Done():
+			case <-ctx.Done():
+				return
+			}
+		}
+		cancel()
+	}()
+
+	return ctx, cancel
+}
+
+// waitForDeployment polls the distribution until CloudFront reports that
+// all changes have been deployed or the context is done.
+func (d *distributionUpdater) waitForDeployment(ctx context.Context, distributionId string) error {
+	for attempt := 1; ; attempt++ {
+		out, err := d.client.GetDistribution(ctx, &cloudfront.GetDistributionInput{
+			Id: aws.String(distributionId),
+		})
+
+		// nothing left to deploy
+		if isNoSuchDistribution(err) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if aws.ToString(out.Distribution.Status) == "Deployed" {
+			return nil
+		}
+
+		if err := sleep(ctx, backoff(attempt, minDeploymentPoll, maxDeploymentPoll)); err != nil {
+			return fmt.Errorf("distribution %s is still %s: %w", distributionId, aws.ToString(out.Distribution.Status), err)
+		}
+	}
+}
+
+// backoff returns the exponential delay with jitter before the next attempt.
+func backoff(attempt int, min time.Duration, max time.Duration) time.Duration {
+	delay := max
+	if attempt < 16 {
+		delay = min << (attempt - 1)
+	}
+	if delay > max {
+		delay = max
+	}
+	return min/2 + time.Duration(rand.Int63n(int64(delay)))
+}
+
+// sleep waits for the given duration or until the context is done.
+func sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
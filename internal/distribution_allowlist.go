@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"path"
+	"sync"
+)
+
+// distributionAllowlist restricts the distributions the provider may modify
+// to those whose id matches one of the glob patterns or which carry all of
+// the tags. Without patterns and tags every distribution is allowed.
+type distributionAllowlist struct {
+	client   *cloudfront.Client
+	patterns []string
+	tags     map[string]string
+
+	mutex  sync.Mutex
+	tagged map[string]bool
+}
+
+func newDistributionAllowlist(client *cloudfront.Client, patterns []string, tags map[string]string) (*distributionAllowlist, error) {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	return &distributionAllowlist{
+		client:   client,
+		patterns: patterns,
+		tags:     tags,
+		tagged:   map[string]bool{},
+	}, nil
+}
+
+// check returns an error if the distribution may not be modified.
+func (a *distributionAllowlist) check(ctx context.Context, distributionId string) error {
+	if a == nil || (len(a.patterns) == 0 && len(a.tags) == 0) {
+		return nil
+	}
+
+	for _, pattern := range a.patterns {
+		if matched, _ := path.Match(pattern, distributionId); matched {
+			return nil
+		}
+	}
+
+	if len(a.tags) > 0 {
+		tagged, err := a.hasTags(ctx, distributionId)
+		// a deleted distribution can not be modified anymore
+		if isNoSuchDistribution(err) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("cannot read the tags of distribution %s: %w", distributionId, err)
+		}
+		if tagged {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("distribution %s is not allowed by allowed_distribution_ids or allowed_distribution_tags", distributionId)
+}
+
+// hasTags reports whether the distribution carries all tags of the
+// allowlist. The result is cached, as tags are not expected to change while
+// Terraform runs.
+func (a *distributionAllowlist) hasTags(ctx context.Context, distributionId string) (bool, error) {
+	a.mutex.Lock()
+	tagged, cached := a.tagged[distributionId]
+	a.mutex.Unlock()
+	if cached {
+		return tagged, nil
+	}
+
+	distribution, err := a.client.GetDistribution(ctx, &cloudfront.GetDistributionInput{
+		Id: aws.String(distributionId),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	out, err := a.client.ListTagsForResource(ctx, &cloudfront.ListTagsForResourceInput{
+		Resource: distribution.Distribution.ARN,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	tags := map[string]string{}
+	if out.Tags != nil {
+		for _, tag := range out.Tags.Items {
+			tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+	}
+
+	tagged = true
+	for key, value := range a.tags {
+		if actual, ok := tags[key]; !ok || actual != value {
+			tagged = false
+		}
+	}
+
+	a.mutex.Lock()
+	a.tagged[distributionId] = tagged
+	a.mutex.Unlock()
+	return tagged, nil
+}
+
+// verifyPlan checks the distribution of a planned resource, or of the
+// resource being destroyed, so a disallowed distribution fails at plan time.
+func (a *distributionAllowlist) verifyPlan(ctx context.Context, req tfsdk.ModifyResourcePlanRequest) diag.Diagnostics {
+	var diags diag.Diagnostics
+	distributionIdPath := tftypes.NewAttributePath().WithAttributeName("distribution_id")
+
+	var distributionId types.String
+	if req.Plan.Raw.IsNull() {
+		diags.Append(req.State.GetAttribute(ctx, distributionIdPath, &distributionId)...)
+	} else {
+		diags.Append(req.Plan.GetAttribute(ctx, distributionIdPath, &distributionId)...)
+	}
+	if diags.HasError() || distributionId.IsNull() || distributionId.IsUnknown() {
+		return diags
+	}
+
+	if err := a.check(ctx, distributionId.Value); err != nil {
+		diags.AddAttributeError(distributionIdPath, "Distribution not allowed", err.Error())
+	}
+	return diags
+}
@@ -0,0 +1,413 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	cloudfrontTypes "github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"golang.org/x/exp/slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDistributionClient keeps the config of a single distribution in memory
+// and rejects updates with a stale ETag like CloudFront does.
+type fakeDistributionClient struct {
+	mutex   sync.Mutex
+	config  cloudfrontTypes.DistributionConfig
+	version int
+	updates int
+
+	// conflicts is the number of updates which fail as if the distribution
+	// had been changed concurrently
+	conflicts int
+	// reject returns an error for configs CloudFront would not accept
+	reject func(config *cloudfrontTypes.DistributionConfig) error
+	// updated is called after every accepted update
+	updated func()
+}
+
+func newFakeDistributionClient() *fakeDistributionClient {
+	return &fakeDistributionClient{
+		config: cloudfrontTypes.DistributionConfig{
+			Origins:        &cloudfrontTypes.Origins{Quantity: aws.Int32(0)},
+			CacheBehaviors: &cloudfrontTypes.CacheBehaviors{Quantity: aws.Int32(0)},
+		},
+	}
+}
+
+func (f *fakeDistributionClient) GetDistribution(_ context.Context, params *cloudfront.GetDistributionInput, _ ...func(*cloudfront.Options)) (*cloudfront.GetDistributionOutput, error) {
+	return &cloudfront.GetDistributionOutput{
+		Distribution: &cloudfrontTypes.Distribution{Id: params.Id, Status: aws.String("Deployed")},
+	}, nil
+}
+
+func (f *fakeDistributionClient) GetDistributionConfig(_ context.Context, _ *cloudfront.GetDistributionConfigInput, _ ...func(*cloudfront.Options)) (*cloudfront.GetDistributionConfigOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	config := f.config
+	origins := *f.config.Origins
+	origins.Items = slices.Clone(f.config.Origins.Items)
+	config.Origins = &origins
+	behaviours := *f.config.CacheBehaviors
+	behaviours.Items = slices.Clone(f.config.CacheBehaviors.Items)
+	config.CacheBehaviors = &behaviours
+	return &cloudfront.GetDistributionConfigOutput{
+		DistributionConfig: &config,
+		ETag:               aws.String(strconv.Itoa(f.version)),
+	}, nil
+}
+
+func (f *fakeDistributionClient) UpdateDistribution(_ context.Context, params *cloudfront.UpdateDistributionInput, _ ...func(*cloudfront.Options)) (*cloudfront.UpdateDistributionOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.updates++
+	if f.conflicts > 0 {
+		f.conflicts--
+		f.version++
+	}
+	if aws.ToString(params.IfMatch) != strconv.Itoa(f.version) {
+		return nil, &cloudfrontTypes.PreconditionFailed{}
+	}
+	if f.reject != nil {
+		if err := f.reject(params.DistributionConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	f.config = *params.DistributionConfig
+	f.version++
+	if f.updated != nil {
+		f.updated()
+	}
+	return &cloudfront.UpdateDistributionOutput{}, nil
+}
+
+func (f *fakeDistributionClient) originIds() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	var ids []string
+	for _, origin := range f.config.Origins.Items {
+		ids = append(ids, aws.ToString(origin.Id))
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func (f *fakeDistributionClient) pathPatterns() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	var patterns []string
+	for _, behaviour := range f.config.CacheBehaviors.Items {
+		patterns = append(patterns, aws.ToString(behaviour.PathPattern))
+	}
+	return patterns
+}
+
+func addOrigin(id string) distributionMutation {
+	return func(config *cloudfrontTypes.DistributionConfig) error {
+		config.Origins.Items = append(config.Origins.Items, cloudfrontTypes.Origin{Id: aws.String(id)})
+		*config.Origins.Quantity++
+		return nil
+	}
+}
+
+// updateConcurrently updates the distribution with all mutations at the same
+// time and returns their errors.
+func updateConcurrently(ctx context.Context, updater *distributionUpdater, mutations map[string]distributionMutation) map[string]error {
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	errs := map[string]error{}
+	for name, mutation := range mutations {
+		wg.Add(1)
+		go func(name string, mutation distributionMutation) {
+			defer wg.Done()
+			err := updater.update(ctx, "E1", mutation)
+			mutex.Lock()
+			errs[name] = err
+			mutex.Unlock()
+		}(name, mutation)
+	}
+	wg.Wait()
+	return errs
+}
+
+func TestUpdateBatchesConcurrentMutations(t *testing.T) {
+	client := newFakeDistributionClient()
+	updater := newDistributionUpdater(client, defaultMaxUpdateAttempts, nil)
+
+	errs := updateConcurrently(context.Background(), updater, map[string]distributionMutation{
+		"a": addOrigin("a"),
+		"b": addOrigin("b"),
+		"c": addOrigin("c"),
+		"d": func(config *cloudfrontTypes.DistributionConfig) error {
+			return errUnchanged
+		},
+	})
+
+	for name, err := range errs {
+		if err != nil {
+			t.Errorf("mutation %s failed: %v", name, err)
+		}
+	}
+	if client.updates != 1 {
+		t.Errorf("expected a single update, got %d", client.updates)
+	}
+	if ids := client.originIds(); !slices.Equal(ids, []string{"a", "b", "c"}) {
+		t.Errorf("expected origins [a b c], got %v", ids)
+	}
+}
+
+func TestUpdateRetriesOnPreconditionFailed(t *testing.T) {
+	client := newFakeDistributionClient()
+	client.conflicts = 1
+	updater := newDistributionUpdater(client, defaultMaxUpdateAttempts, nil)
+
+	if err := updater.update(context.Background(), "E1", addOrigin("a")); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if client.updates != 2 {
+		t.Errorf("expected the update to be retried once, got %d updates", client.updates)
+	}
+	if ids := client.originIds(); !slices.Equal(ids, []string{"a"}) {
+		t.Errorf("expected origins [a], got %v", ids)
+	}
+}
+
+func TestUpdateGivesUpAfterMaxAttempts(t *testing.T) {
+	client := newFakeDistributionClient()
+	client.conflicts = 2
+	updater := newDistributionUpdater(client, 2, nil)
+
+	err := updater.update(context.Background(), "E1", addOrigin("a"))
+	if !isPreconditionFailed(err) {
+		t.Fatalf("expected a precondition failure, got %v", err)
+	}
+	if client.updates != 2 {
+		t.Errorf("expected 2 updates, got %d", client.updates)
+	}
+}
+
+func TestUpdateIsolatesRejectedMutation(t *testing.T) {
+	invalidOrigin := errors.New("invalid origin")
+	client := newFakeDistributionClient()
+	client.reject = func(config *cloudfrontTypes.DistributionConfig) error {
+		for _, origin := range config.Origins.Items {
+			if aws.ToString(origin.Id) == "invalid" {
+				return invalidOrigin
+			}
+		}
+		return nil
+	}
+	updater := newDistributionUpdater(client, defaultMaxUpdateAttempts, nil)
+
+	errs := updateConcurrently(context.Background(), updater, map[string]distributionMutation{
+		"a":       addOrigin("a"),
+		"invalid": addOrigin("invalid"),
+		"b":       addOrigin("b"),
+		"failing": func(config *cloudfrontTypes.DistributionConfig) error {
+			return errors.New("failing mutation")
+		},
+	})
+
+	if !errors.Is(errs["invalid"], invalidOrigin) {
+		t.Errorf("expected the invalid origin to be rejected, got %v", errs["invalid"])
+	}
+	if errs["failing"] == nil || errs["failing"].Error() != "failing mutation" {
+		t.Errorf("expected the error of the failing mutation, got %v", errs["failing"])
+	}
+	for _, name := range []string{"a", "b"} {
+		if errs[name] != nil {
+			t.Errorf("mutation %s failed: %v", name, errs[name])
+		}
+	}
+	if ids := client.originIds(); !slices.Equal(ids, []string{"a", "b"}) {
+		t.Errorf("expected origins [a b], got %v", ids)
+	}
+}
+
+func TestUpdateSkipsMutationsOfDoneContexts(t *testing.T) {
+	client := newFakeDistributionClient()
+	updater := newDistributionUpdater(client, defaultMaxUpdateAttempts, nil)
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var wg sync.WaitGroup
+	var cancelledErr, activeErr error
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		cancelledErr = updater.update(cancelled, "E1", addOrigin("cancelled"))
+	}()
+	go func() {
+		defer wg.Done()
+		activeErr = updater.update(context.Background(), "E1", addOrigin("active"))
+	}()
+	wg.Wait()
+
+	if !errors.Is(cancelledErr, context.Canceled) {
+		t.Errorf("expected the cancelled mutation to fail with its context, got %v", cancelledErr)
+	}
+	if activeErr != nil {
+		t.Errorf("active mutation failed: %v", activeErr)
+	}
+	if ids := client.originIds(); !slices.Equal(ids, []string{"active"}) {
+		t.Errorf("expected origins [active], got %v", ids)
+	}
+}
+
+func TestUpdateSkipsMutationsTimingOutDuringBackoff(t *testing.T) {
+	client := newFakeDistributionClient()
+	client.conflicts = 1
+	updater := newDistributionUpdater(client, defaultMaxUpdateAttempts, nil)
+
+	// the first attempt fails after the batch window and the context times
+	// out during the backoff, which lasts at least half the minimal backoff
+	ctx, cancel := context.WithTimeout(context.Background(), batchWindow+minUpdateBackoff/4)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var timeoutErr, activeErr error
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		timeoutErr = updater.update(ctx, "E1", addOrigin("timeout"))
+	}()
+	go func() {
+		defer wg.Done()
+		activeErr = updater.update(context.Background(), "E1", addOrigin("second"))
+	}()
+	wg.Wait()
+
+	if !errors.Is(timeoutErr, context.DeadlineExceeded) {
+		t.Errorf("expected the mutation to time out, got %v", timeoutErr)
+	}
+	if activeErr != nil {
+		t.Errorf("active mutation failed: %v", activeErr)
+	}
+	if ids := client.originIds(); !slices.Equal(ids, []string{"second"}) {
+		t.Errorf("expected origins [second], got %v", ids)
+	}
+}
+
+func TestUpdateReportsWrittenMutationAfterTimeout(t *testing.T) {
+	client := newFakeDistributionClient()
+	updater := newDistributionUpdater(client, defaultMaxUpdateAttempts, nil)
+
+	// the context times out while the update is in flight
+	ctx, cancel := context.WithTimeout(context.Background(), batchWindow+100*time.Millisecond)
+	defer cancel()
+	client.updated = func() {
+		<-ctx.Done()
+	}
+
+	if err := updater.update(ctx, "E1", addOrigin("a")); err != nil {
+		t.Errorf("expected the written mutation to succeed, got %v", err)
+	}
+	if ids := client.originIds(); !slices.Equal(ids, []string{"a"}) {
+		t.Errorf("expected origins [a], got %v", ids)
+	}
+}
+
+func TestUpdateAppliesMutationsInOrder(t *testing.T) {
+	client := newFakeDistributionClient()
+	updater := newDistributionUpdater(client, defaultMaxUpdateAttempts, nil)
+
+	var mutex sync.Mutex
+	var applied []string
+	record := func(name string) distributionMutation {
+		return func(config *cloudfrontTypes.DistributionConfig) error {
+			mutex.Lock()
+			applied = append(applied, name)
+			mutex.Unlock()
+			return addOrigin(name)(config)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i, name := range []string{"third", "second", "first"} {
+		wg.Add(1)
+		go func(order int64, name string) {
+			defer wg.Done()
+			if err := updater.updateInOrder(context.Background(), "E1", order, record(name)); err != nil {
+				t.Errorf("mutation %s failed: %v", name, err)
+			}
+		}(int64(2-i), name)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := updater.update(context.Background(), "E1", record("unordered")); err != nil {
+			t.Errorf("unordered mutation failed: %v", err)
+		}
+	}()
+	wg.Wait()
+
+	if !slices.Equal(applied, []string{"unordered", "first", "second", "third"}) {
+		t.Errorf("expected mutations to be applied in order, got %v", applied)
+	}
+}
+
+func TestUpdateKeepsPositionOfCacheBehavioursWithoutPrecedence(t *testing.T) {
+	client := newFakeDistributionClient()
+	updater := newDistributionUpdater(client, defaultMaxUpdateAttempts, nil)
+
+	behaviour := func(pathPattern string, precedence types.Int64) CacheBehaviour {
+		return CacheBehaviour{
+			DistributionId:       types.String{Value: "E1"},
+			OriginId:             types.String{Value: "origin"},
+			ViewerProtocolPolicy: types.String{Value: "allow-all"},
+			PathPattern:          types.String{Value: pathPattern},
+			Precedence:           precedence,
+			CachePolicyId:        types.String{Value: "policy"},
+		}
+	}
+
+	var precedence int
+	for _, pathPattern := range []string{"/a/*", "/b/*"} {
+		created := behaviour(pathPattern, types.Int64{Null: true})
+		if err := updater.updateInOrder(context.Background(), "E1", created.insertOrder(), created.createMutation(&precedence)); err != nil {
+			t.Fatalf("failed to create %s: %v", pathPattern, err)
+		}
+	}
+
+	// /b/* is updated without a configured precedence while /c/* is inserted
+	// in front of all cache behaviours
+	created := behaviour("/c/*", types.Int64{Value: 0})
+	prior := behaviour("/b/*", types.Int64{Value: 1})
+	updated := behaviour("/b/*", types.Int64{Unknown: true})
+	updated.Compress = types.Bool{Value: true}
+
+	var createdPrecedence, updatedPrecedence int
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if err := updater.updateInOrder(context.Background(), "E1", updated.insertOrder(), updated.updateMutation(prior, false, &updatedPrecedence)); err != nil {
+			t.Errorf("failed to update /b/*: %v", err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if err := updater.updateInOrder(context.Background(), "E1", created.insertOrder(), created.createMutation(&createdPrecedence)); err != nil {
+			t.Errorf("failed to create /c/*: %v", err)
+		}
+	}()
+	wg.Wait()
+
+	if patterns := client.pathPatterns(); !slices.Equal(patterns, []string{"/c/*", "/a/*", "/b/*"}) {
+		t.Errorf("expected cache behaviours [/c/* /a/* /b/*], got %v", patterns)
+	}
+	if createdPrecedence != 0 || updatedPrecedence != 2 {
+		t.Errorf("expected precedences 0 and 2, got %d and %d", createdPrecedence, updatedPrecedence)
+	}
+}
@@ -5,13 +5,20 @@ import (
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
-	"github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	cloudfrontTypes "github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
 	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
 	"golang.org/x/exp/slices"
+	"strings"
 )
 
 type OriginResource struct {
-	client *cloudfront.Client
+	client            *cloudfront.Client
+	distributions     *distributionUpdater
+	origins           *plannedOrigins
+	allowlist         *distributionAllowlist
+	waitForDeployment bool
 }
 
 // Create is called when the provider must create a new resource. Config
@@ -26,24 +33,14 @@ func (o OriginResource) Create(ctx context.Context, req tfsdk.CreateResourceRequ
 		return
 	}
 
-	out, err := o.client.GetDistributionConfig(ctx, &cloudfront.GetDistributionConfigInput{
-		Id: aws.String(plan.DistributionId.Value),
-	})
-
-	if err != nil {
-		resp.Diagnostics.AddError("failed to get distribution config", err.Error())
-		return
-	}
+	ctx, cancel := withTimeout(ctx, plan.Timeouts.create())
+	defer cancel()
 
-	distributionConfig := out.DistributionConfig
-	// Add new Origin to existing configuration
-	distributionConfig.Origins.Items = append(distributionConfig.Origins.Items, OriginFromResource(plan))
-	*distributionConfig.Origins.Quantity++
-
-	_, err = o.client.UpdateDistribution(ctx, &cloudfront.UpdateDistributionInput{
-		DistributionConfig: distributionConfig,
-		Id:                 aws.String(plan.DistributionId.Value),
-		IfMatch:            out.ETag,
+	err := o.distributions.update(ctx, plan.DistributionId.Value, func(distributionConfig *cloudfrontTypes.DistributionConfig) error {
+		// Add new Origin to existing configuration
+		distributionConfig.Origins.Items = append(distributionConfig.Origins.Items, OriginFromResource(plan))
+		*distributionConfig.Origins.Quantity++
+		return nil
 	})
 
 	if err != nil {
@@ -57,6 +54,13 @@ func (o OriginResource) Create(ctx context.Context, req tfsdk.CreateResourceRequ
 	if resp.Diagnostics.HasError() {
 		return
 	}
+
+	if *toBool(plan.WaitForDeployment, o.waitForDeployment) {
+		err = o.distributions.waitForDeployment(ctx, plan.DistributionId.Value)
+		if err != nil {
+			resp.Diagnostics.AddError("failed to wait for deployment of distribution", err.Error())
+		}
+	}
 }
 
 // Read is called when the provider must read resource values in order
@@ -71,6 +75,38 @@ func (o OriginResource) Read(ctx context.Context, req tfsdk.ReadResourceRequest,
 		return
 	}
 
+	out, err := o.client.GetDistributionConfig(ctx, &cloudfront.GetDistributionConfigInput{
+		Id: aws.String(state.DistributionId.Value),
+	})
+
+	if isNoSuchDistribution(err) {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	if err != nil {
+		resp.Diagnostics.AddError("failed to get distribution config", err.Error())
+		return
+	}
+
+	idx := slices.IndexFunc(out.DistributionConfig.Origins.Items, func(origin cloudfrontTypes.Origin) bool {
+		return *origin.Id == state.Id.Value
+	})
+
+	// the origin has been removed outside of terraform
+	if idx == -1 {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	refreshed := OriginToResource(state.DistributionId.Value, out.DistributionConfig.Origins.Items[idx]).keepUnsetDefaults(state)
+	refreshed.WaitForDeployment = state.WaitForDeployment
+	refreshed.Timeouts = state.Timeouts
+	refreshed.OnDeleteDependentBehaviours = state.OnDeleteDependentBehaviours
+	refreshed.ReassignTo = state.ReassignTo
+	refreshed.MoveInPlace = state.MoveInPlace
+	state = refreshed
+
 	diags = resp.State.Set(ctx, &state)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
@@ -99,42 +135,59 @@ func (o OriginResource) Update(ctx context.Context, req tfsdk.UpdateResourceRequ
 		return
 	}
 
-	// distribution changed, remove origin from old distribution
-	if state.DistributionId.Value != plan.DistributionId.Value {
-		err := o.deleteFromDistribution(ctx, state)
+	ctx, cancel := withTimeout(ctx, plan.Timeouts.update())
+	defer cancel()
+
+	// distribution changed with move_in_place, remove origin from old distribution
+	moved := state.DistributionId.Value != plan.DistributionId.Value
+	if moved {
+		_, err := o.deleteFromDistribution(ctx, state, false)
 		if err != nil {
 			resp.Diagnostics.AddError("failed to remove origin from previous distribution", err.Error())
+			return
 		}
 	}
 
-	out, err := o.client.GetDistributionConfig(ctx, &cloudfront.GetDistributionConfigInput{
-		Id: aws.String(plan.DistributionId.Value),
-	})
-
-	if err != nil {
-		resp.Diagnostics.AddError("failed to get distribution config", err.Error())
-		return
-	}
+	err := o.distributions.update(ctx, plan.DistributionId.Value, func(distributionConfig *cloudfrontTypes.DistributionConfig) error {
+		idx := -1
+		if !moved {
+			idx = indexOfOrigin(distributionConfig, state.Id.Value)
+		}
 
-	distributionConfig := out.DistributionConfig
+		if (moved || plan.Id.Value != state.Id.Value) && indexOfOrigin(distributionConfig, plan.Id.Value) != -1 {
+			return fmt.Errorf("the distribution %s already has an origin with id %s", plan.DistributionId.Value, plan.Id.Value)
+		}
 
-	idx := slices.IndexFunc(distributionConfig.Origins.Items, func(origin types.Origin) bool {
-		return *origin.Id == state.Id.Value
+		if idx == -1 {
+			distributionConfig.Origins.Items = append(distributionConfig.Origins.Items, OriginFromResource(plan))
+			*distributionConfig.Origins.Quantity++
+		} else {
+			distributionConfig.Origins.Items[idx] = OriginFromResource(plan)
+			// origin id changed with move_in_place, keep the cache behaviours targeting it
+			retargetBehaviours(distributionConfig, state.Id.Value, plan.Id.Value)
+		}
+		return nil
 	})
 
-	if idx == -1 {
-		distributionConfig.Origins.Items = append(distributionConfig.Origins.Items, OriginFromResource(plan))
-		*distributionConfig.Origins.Quantity++
-	} else {
-		distributionConfig.Origins.Items[idx] = OriginFromResource(plan)
+	if err != nil && moved {
+		// restore the origin in the previous distribution, the context of the
+		// operation might already be done
+		rollbackCtx, cancel := context.WithTimeout(context.Background(), rollbackTimeout)
+		rollbackErr := o.distributions.update(rollbackCtx, state.DistributionId.Value, func(distributionConfig *cloudfrontTypes.DistributionConfig) error {
+			if indexOfOrigin(distributionConfig, state.Id.Value) != -1 {
+				return errUnchanged
+			}
+			distributionConfig.Origins.Items = append(distributionConfig.Origins.Items, OriginFromResource(state))
+			*distributionConfig.Origins.Quantity++
+			return nil
+		})
+		cancel()
+
+		if rollbackErr != nil {
+			resp.Diagnostics.AddError("failed to restore origin in previous distribution", rollbackErr.Error())
+		}
 	}
 
-	_, err = o.client.UpdateDistribution(ctx, &cloudfront.UpdateDistributionInput{
-		DistributionConfig: distributionConfig,
-		Id:                 aws.String(plan.DistributionId.Value),
-		IfMatch:            out.ETag,
-	})
-
 	if err != nil {
 		resp.Diagnostics.AddError("failed to update distribution", err.Error())
 		return
@@ -146,6 +199,13 @@ func (o OriginResource) Update(ctx context.Context, req tfsdk.UpdateResourceRequ
 	if resp.Diagnostics.HasError() {
 		return
 	}
+
+	if *toBool(plan.WaitForDeployment, o.waitForDeployment) {
+		err = o.distributions.waitForDeployment(ctx, plan.DistributionId.Value)
+		if err != nil {
+			resp.Diagnostics.AddError("failed to wait for deployment of distribution", err.Error())
+		}
+	}
 }
 
 // Delete is called when the provider must delete the resource. Config
@@ -162,55 +222,181 @@ func (o OriginResource) Delete(ctx context.Context, req tfsdk.DeleteResourceRequ
 		return
 	}
 
-	err := o.deleteFromDistribution(ctx, state)
+	ctx, cancel := withTimeout(ctx, state.Timeouts.delete())
+	defer cancel()
+
+	dependents, err := o.deleteFromDistribution(ctx, state, true)
 
 	if err != nil {
 		resp.Diagnostics.AddError("failed to delete origin from distribution", err.Error())
 		return
 	}
 
+	warnDependentBehaviours(state, dependents, &resp.Diagnostics)
+
+	if *toBool(state.WaitForDeployment, o.waitForDeployment) {
+		err = o.distributions.waitForDeployment(ctx, state.DistributionId.Value)
+		if err != nil {
+			resp.Diagnostics.AddError("failed to wait for deployment of distribution", err.Error())
+			return
+		}
+	}
+
 	resp.State.RemoveResource(ctx)
 }
 
-func (o OriginResource) deleteFromDistribution(ctx context.Context, origin Origin) error {
-	out, err := o.client.GetDistributionConfig(ctx, &cloudfront.GetDistributionConfigInput{
-		Id: aws.String(origin.DistributionId.Value),
+// deleteFromDistribution removes the origin from its distribution. Cache
+// behaviours targeting the origin are handled according to
+// on_delete_dependent_behaviours, it returns the path patterns of the
+// cache behaviours which have been removed or reassigned. Without
+// releaseDependents the origin is only removed if no cache behaviour
+// targets it.
+func (o OriginResource) deleteFromDistribution(ctx context.Context, origin Origin, releaseDependents bool) ([]string, error) {
+	var dependents []string
+	err := o.distributions.update(ctx, origin.DistributionId.Value, func(distributionConfig *cloudfrontTypes.DistributionConfig) error {
+		idx := indexOfOrigin(distributionConfig, origin.Id.Value)
+
+		// the origin has already been removed
+		if idx == -1 {
+			dependents = nil
+			return errUnchanged
+		}
+
+		if !releaseDependents {
+			if blocking := origin.dependentBehaviours(distributionConfig); len(blocking) > 0 {
+				return fmt.Errorf("origin %s is still targeted by the cache behaviours %s, move_in_place only moves origins without dependent cache behaviours, as they could not be restored if adding the origin to the new distribution fails", origin.Id.Value, strings.Join(blocking, ", "))
+			}
+		}
+
+		var err error
+		dependents, err = origin.releaseDependentBehaviours(distributionConfig)
+		if err != nil {
+			return err
+		}
+
+		distributionConfig.Origins.Items = slices.Delete(distributionConfig.Origins.Items, idx, idx+1)
+		*distributionConfig.Origins.Quantity--
+		return nil
 	})
 
+	// the distribution and the origin with it are already gone
+	if isNoSuchDistribution(err) {
+		return nil, nil
+	}
+
+	return dependents, err
+}
+
+// ImportState is called when the provider must import the state of an
+// origin. The import id has the form <distribution_id>/<origin_id>, Read
+// then populates the remaining attributes from the distribution config.
+func (o OriginResource) ImportState(ctx context.Context, req tfsdk.ImportResourceStateRequest, resp *tfsdk.ImportResourceStateResponse) {
+	distributionId, originId, err := splitImportId(req.ID, "origin_id")
 	if err != nil {
-		return err
+		resp.Diagnostics.AddError("invalid import id", err.Error())
+		return
 	}
 
-	idx := slices.IndexFunc(out.DistributionConfig.Origins.Items, func(o types.Origin) bool {
-		return *o.Id == origin.Id.Value
-	})
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("distribution_id"), distributionId)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("origin_id"), originId)...)
+}
 
-	if idx == -1 {
-		return fmt.Errorf("the origin with id %s can not be found, it has been modified or removed", origin.Id)
+// ModifyPlan is called when the provider has an opportunity to modify the
+// plan. It records the planned origin, so cache behaviours planned in the
+// same run can target it before it exists, and verifies that the origin
+// dependent cache behaviours are reassigned to exists.
+func (o OriginResource) ModifyPlan(ctx context.Context, req tfsdk.ModifyResourcePlanRequest, resp *tfsdk.ModifyResourcePlanResponse) {
+	resp.Diagnostics.Append(o.allowlist.verifyPlan(ctx, req)...)
+	if resp.Diagnostics.HasError() {
+		return
 	}
 
-	out.DistributionConfig.Origins.Items = append(out.DistributionConfig.Origins.Items[:idx], out.DistributionConfig.Origins.Items[idx+1:]...)
-	*out.DistributionConfig.Origins.Quantity--
+	// resource is being destroyed
+	if req.Plan.Raw.IsNull() {
+		return
+	}
 
-	// remove cache behaviour associated with this origin, otherwise we can not delete the origin
-	for i, item := range out.DistributionConfig.CacheBehaviors.Items {
-		if *item.TargetOriginId == origin.Id.Value {
-			out.DistributionConfig.CacheBehaviors.Items = append(out.DistributionConfig.CacheBehaviors.Items[:i], out.DistributionConfig.CacheBehaviors.Items[i+1:]...)
-			*out.DistributionConfig.CacheBehaviors.Quantity--
-		}
+	var distributionId, originId, reassignTo types.String
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("distribution_id"), &distributionId)...)
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("origin_id"), &originId)...)
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("reassign_to"), &reassignTo)...)
+	if resp.Diagnostics.HasError() {
+		return
 	}
 
-	_, err = o.client.UpdateDistribution(ctx, &cloudfront.UpdateDistributionInput{
-		DistributionConfig: out.DistributionConfig,
-		Id:                 aws.String(origin.DistributionId.Value),
-		IfMatch:            out.ETag,
-	})
+	if o.origins != nil && !distributionId.Unknown && !originId.Unknown {
+		o.origins.add(distributionId.Value, originId.Value)
+	}
 
-	return err
+	// deletes run from state, so a missing target would block the deletion
+	// of the origin until its config is restored
+	if !distributionId.Unknown && !reassignTo.IsNull() && !reassignTo.IsUnknown() {
+		resp.Diagnostics.Append(verifyPlannedOrigin(ctx, o.client, o.origins, distributionId.Value, reassignTo.Value, tftypes.NewAttributePath().WithAttributeName("reassign_to"), false)...)
+	}
 }
 
-// Import resource
-//func (o OriginResource) ImportState(ctx context.Context, req tfsdk.ImportResourceStateRequest, resp *tfsdk.ImportResourceStateResponse) {
-//	// Save the import identifier in the id attribute
-//	tfsdk.ResourceImportStatePassthroughID(ctx, tftypes.NewAttributePath().WithAttributeName("distribution_id"), req, resp)
-//}
+// ValidateConfig validates combinations of attributes which the schema can
+// not express.
+func (o OriginResource) ValidateConfig(ctx context.Context, req tfsdk.ValidateResourceConfigRequest, resp *tfsdk.ValidateResourceConfigResponse) {
+	var s3OriginConfig, customOriginConfig types.Object
+	var originAccessIdentity, originAccessControlId types.String
+	var originId, onDeleteDependentBehaviours, reassignTo types.String
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("s3_origin_config"), &s3OriginConfig)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("custom_origin_config"), &customOriginConfig)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("s3_origin_config").WithAttributeName("origin_access_identity"), &originAccessIdentity)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("origin_access_control_id"), &originAccessControlId)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("origin_id"), &originId)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("on_delete_dependent_behaviours"), &onDeleteDependentBehaviours)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("reassign_to"), &reassignTo)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if !s3OriginConfig.IsUnknown() && !customOriginConfig.IsUnknown() {
+		if !s3OriginConfig.IsNull() && !customOriginConfig.IsNull() {
+			resp.Diagnostics.AddAttributeError(
+				tftypes.NewAttributePath().WithAttributeName("custom_origin_config"),
+				"Conflicting origin configs",
+				"an origin is either an S3 bucket or a custom origin, set only one of s3_origin_config and custom_origin_config",
+			)
+		} else if s3OriginConfig.IsNull() && customOriginConfig.IsNull() {
+			resp.Diagnostics.AddError(
+				"Missing origin config",
+				"an origin needs either s3_origin_config or custom_origin_config",
+			)
+		}
+	}
+
+	if originAccessIdentity.Value != "" && originAccessControlId.Value != "" {
+		resp.Diagnostics.AddAttributeError(
+			tftypes.NewAttributePath().WithAttributeName("origin_access_control_id"),
+			"Conflicting origin access settings",
+			"an origin can either use an origin access identity or an origin access control, remove s3_origin_config.origin_access_identity to use the origin access control",
+		)
+	}
+
+	if !onDeleteDependentBehaviours.IsUnknown() && !reassignTo.IsUnknown() {
+		reassign := onDeleteDependentBehaviours.Value == dependentBehavioursReassign
+		if reassign && reassignTo.IsNull() {
+			resp.Diagnostics.AddAttributeError(
+				tftypes.NewAttributePath().WithAttributeName("reassign_to"),
+				"Missing reassign target",
+				fmt.Sprintf("on_delete_dependent_behaviours %q needs reassign_to, the origin the dependent cache behaviours are reassigned to", dependentBehavioursReassign),
+			)
+		} else if !reassign && !reassignTo.IsNull() {
+			resp.Diagnostics.AddAttributeError(
+				tftypes.NewAttributePath().WithAttributeName("reassign_to"),
+				"Unused reassign target",
+				fmt.Sprintf("reassign_to only applies if on_delete_dependent_behaviours is %q", dependentBehavioursReassign),
+			)
+		}
+	}
+
+	if !reassignTo.IsNull() && !reassignTo.IsUnknown() && !originId.IsUnknown() && reassignTo.Value == originId.Value {
+		resp.Diagnostics.AddAttributeError(
+			tftypes.NewAttributePath().WithAttributeName("reassign_to"),
+			"Invalid reassign target",
+			"the dependent cache behaviours can not be reassigned to the origin which is deleted",
+		)
+	}
+}
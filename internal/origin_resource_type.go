@@ -2,11 +2,15 @@ package internal
 
 import (
 	"context"
+	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	cloudfrontTypes "github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"golang.org/x/exp/slices"
+	"strings"
 )
 
 type Origin struct {
@@ -21,6 +25,12 @@ type Origin struct {
 	ConnectionTimeout     types.Int64         `tfsdk:"connection_timeout"`
 	OriginShield          *OriginShield       `tfsdk:"origin_shield"`
 	OriginAccessControlId types.String        `tfsdk:"origin_access_control_id"`
+	WaitForDeployment     types.Bool          `tfsdk:"wait_for_deployment"`
+	Timeouts              *Timeouts           `tfsdk:"timeouts"`
+
+	OnDeleteDependentBehaviours types.String `tfsdk:"on_delete_dependent_behaviours"`
+	ReassignTo                  types.String `tfsdk:"reassign_to"`
+	MoveInPlace                 types.Bool   `tfsdk:"move_in_place"`
 }
 
 type OriginShield struct {
@@ -48,6 +58,23 @@ type CustomOriginConfig struct {
 
 type OriginResourceType struct{}
 
+// CloudFront allows read and keep-alive timeouts of up to 60 seconds, which
+// can be raised to 180 seconds with a quota increase.
+const maxOriginTimeout = 180
+
+const (
+	originAccessIdentityPath = "origin-access-identity/cloudfront/"
+	originAccessIdentityArn  = "arn:aws:iam::cloudfront:user/CloudFront Origin Access Identity "
+)
+
+// Modes of on_delete_dependent_behaviours, which decide what happens to cache
+// behaviours targeting an origin when it is deleted.
+const (
+	dependentBehavioursFail     = "fail"
+	dependentBehavioursCascade  = "cascade"
+	dependentBehavioursReassign = "reassign"
+)
+
 func (o Origin) getCloudfrontCustomHeaders() *cloudfrontTypes.CustomHeaders {
 	if o.CustomHeaders == nil || len(o.CustomHeaders) == 0 {
 		return &cloudfrontTypes.CustomHeaders{
@@ -104,10 +131,16 @@ func (o OriginResourceType) GetSchema(_ context.Context) (tfsdk.Schema, diag.Dia
 			"distribution_id": {
 				Type:     types.StringType,
 				Required: true,
+				PlanModifiers: tfsdk.AttributePlanModifiers{
+					requiresReplaceUnlessMoveInPlace{},
+				},
 			},
 			"origin_id": {
 				Type:     types.StringType,
 				Required: true,
+				PlanModifiers: tfsdk.AttributePlanModifiers{
+					requiresReplaceUnlessMoveInPlace{},
+				},
 			},
 			"origin_domain": {
 				Type:     types.StringType,
@@ -135,7 +168,7 @@ func (o OriginResourceType) GetSchema(_ context.Context) (tfsdk.Schema, diag.Dia
 				Attributes: tfsdk.SingleNestedAttributes(map[string]tfsdk.Attribute{
 					"origin_access_identity": {
 						Type:     types.StringType,
-						Required: true,
+						Optional: true,
 					},
 				}),
 			},
@@ -153,31 +186,78 @@ func (o OriginResourceType) GetSchema(_ context.Context) (tfsdk.Schema, diag.Dia
 					"origin_protocol_policy": {
 						Type:     types.StringType,
 						Required: true,
+						Validators: []tfsdk.AttributeValidator{
+							enumValidator(cloudfrontTypes.OriginProtocolPolicy("").Values()),
+						},
 					},
 					"origin_ssl_protocols": {
 						Type:     types.ListType{ElemType: types.StringType},
 						Optional: true,
+						Validators: []tfsdk.AttributeValidator{
+							elementsValidator{validator: enumValidator(cloudfrontTypes.SslProtocol("").Values())},
+						},
 					},
 					"origin_read_timeout": {
 						Type:     types.Int64Type,
 						Optional: true,
+						Computed: true,
+						Validators: []tfsdk.AttributeValidator{
+							int64RangeValidator{min: 1, max: maxOriginTimeout},
+						},
+						PlanModifiers: tfsdk.AttributePlanModifiers{
+							defaultValue{value: types.Int64{Value: 30}},
+						},
 					},
 					"origin_keep_alive_timeout": {
 						Type:     types.Int64Type,
 						Optional: true,
+						Computed: true,
+						Validators: []tfsdk.AttributeValidator{
+							int64RangeValidator{min: 1, max: maxOriginTimeout},
+						},
+						PlanModifiers: tfsdk.AttributePlanModifiers{
+							defaultValue{value: types.Int64{Value: 5}},
+						},
 					},
 				}),
 			},
 			"connection_attempts": {
 				Type:     types.Int64Type,
 				Optional: true,
+				Computed: true,
+				Validators: []tfsdk.AttributeValidator{
+					int64RangeValidator{min: 1, max: 3},
+				},
+				PlanModifiers: tfsdk.AttributePlanModifiers{
+					defaultValue{value: types.Int64{Value: 3}},
+				},
 			},
 			"connection_timeout": {
 				Type:     types.Int64Type,
 				Optional: true,
+				Computed: true,
+				Validators: []tfsdk.AttributeValidator{
+					int64RangeValidator{min: 1, max: 10},
+				},
+				PlanModifiers: tfsdk.AttributePlanModifiers{
+					defaultValue{value: types.Int64{Value: 10}},
+				},
 			},
 			"origin_shield": {
 				Optional: true,
+				Computed: true,
+				PlanModifiers: tfsdk.AttributePlanModifiers{
+					defaultValue{value: types.Object{
+						AttrTypes: map[string]attr.Type{
+							"enabled":              types.BoolType,
+							"origin_shield_region": types.StringType,
+						},
+						Attrs: map[string]attr.Value{
+							"enabled":              types.Bool{Value: false},
+							"origin_shield_region": types.String{Null: true},
+						},
+					}},
+				},
 				Attributes: tfsdk.SingleNestedAttributes(map[string]tfsdk.Attribute{
 					"enabled": {
 						Type:     types.BoolType,
@@ -193,13 +273,40 @@ func (o OriginResourceType) GetSchema(_ context.Context) (tfsdk.Schema, diag.Dia
 				Type:     types.StringType,
 				Optional: true,
 			},
+			"wait_for_deployment": {
+				Type:     types.BoolType,
+				Optional: true,
+			},
+			"timeouts": timeoutsAttribute(),
+			"on_delete_dependent_behaviours": {
+				Type:        types.StringType,
+				Optional:    true,
+				Description: "What happens to the cache behaviours targeting the origin when it is deleted: `fail` (default) refuses to delete the origin, `cascade` deletes them and `reassign` retargets them to the origin in `reassign_to`.",
+				Validators: []tfsdk.AttributeValidator{
+					oneOfValidator{values: []string{dependentBehavioursFail, dependentBehavioursCascade, dependentBehavioursReassign}},
+				},
+			},
+			"reassign_to": {
+				Type:        types.StringType,
+				Optional:    true,
+				Description: "The id of another origin of the distribution, which the dependent cache behaviours are retargeted to if `on_delete_dependent_behaviours` is `reassign`.",
+			},
+			"move_in_place": {
+				Type:        types.BoolType,
+				Optional:    true,
+				Description: "Move the origin when `distribution_id` or `origin_id` changes instead of replacing it. An origin can only be moved to another distribution while no cache behaviour targets it.",
+			},
 		},
 	}, nil
 }
 
 func (o OriginResourceType) NewResource(_ context.Context, p tfsdk.Provider) (tfsdk.Resource, diag.Diagnostics) {
 	return OriginResource{
-		client: p.(*provider).client,
+		client:            p.(*provider).client,
+		distributions:     p.(*provider).distributions,
+		origins:           p.(*provider).origins,
+		allowlist:         p.(*provider).allowlist,
+		waitForDeployment: p.(*provider).waitForDeployment,
 	}, nil
 }
 
@@ -217,20 +324,227 @@ func OriginFromResource(origin Origin) cloudfrontTypes.Origin {
 
 	var s3OriginConfig *cloudfrontTypes.S3OriginConfig
 	if origin.S3OriginConfig != nil {
+		// origins using an origin access control have an empty origin access identity
 		s3OriginConfig = &cloudfrontTypes.S3OriginConfig{
-			OriginAccessIdentity: aws.String("origin-access-identity/cloudfront/" + origin.S3OriginConfig.OriginAccessIdentity.Value),
+			OriginAccessIdentity: aws.String(""),
+		}
+		if origin.S3OriginConfig.OriginAccessIdentity.Value != "" {
+			s3OriginConfig.OriginAccessIdentity = aws.String(canonicalOriginAccessIdentity(origin.S3OriginConfig.OriginAccessIdentity.Value))
 		}
 	}
 
 	return cloudfrontTypes.Origin{
-		DomainName:         aws.String(origin.Domain.Value),
-		Id:                 aws.String(origin.Id.Value),
-		ConnectionAttempts: toInt32(origin.ConnectionAttempts),
-		ConnectionTimeout:  toInt32(origin.ConnectionTimeout),
-		CustomHeaders:      origin.getCloudfrontCustomHeaders(),
-		CustomOriginConfig: origin.getCustomOriginConfig(),
-		OriginPath:         toString(origin.OriginPath),
-		OriginShield:       originShield,
-		S3OriginConfig:     s3OriginConfig,
+		DomainName:            aws.String(origin.Domain.Value),
+		Id:                    aws.String(origin.Id.Value),
+		ConnectionAttempts:    toInt32(origin.ConnectionAttempts),
+		ConnectionTimeout:     toInt32(origin.ConnectionTimeout),
+		CustomHeaders:         origin.getCloudfrontCustomHeaders(),
+		CustomOriginConfig:    origin.getCustomOriginConfig(),
+		OriginAccessControlId: toStringOrNil(origin.OriginAccessControlId),
+		OriginPath:            toString(origin.OriginPath),
+		OriginShield:          originShield,
+		S3OriginConfig:        s3OriginConfig,
+	}
+}
+
+// OriginToResource converts an origin of a distribution config back into
+// the resource model.
+func OriginToResource(distributionId string, origin cloudfrontTypes.Origin) Origin {
+	var customHeaders []CustomHeader
+	if origin.CustomHeaders != nil {
+		for _, header := range origin.CustomHeaders.Items {
+			customHeaders = append(customHeaders, CustomHeader{
+				HeaderName:  types.String{Value: aws.ToString(header.HeaderName)},
+				HeaderValue: types.String{Value: aws.ToString(header.HeaderValue)},
+			})
+		}
+	}
+
+	var s3OriginConfig *S3OriginConfig
+	if origin.S3OriginConfig != nil {
+		s3OriginConfig = &S3OriginConfig{
+			OriginAccessIdentity: fromString(aws.String(strings.TrimPrefix(aws.ToString(origin.S3OriginConfig.OriginAccessIdentity), originAccessIdentityPath))),
+		}
+	}
+
+	var customOriginConfig *CustomOriginConfig
+	if origin.CustomOriginConfig != nil {
+		var originSslProtocols []types.String
+		if origin.CustomOriginConfig.OriginSslProtocols != nil {
+			for _, sslProtocol := range origin.CustomOriginConfig.OriginSslProtocols.Items {
+				originSslProtocols = append(originSslProtocols, types.String{Value: string(sslProtocol)})
+			}
+		}
+
+		customOriginConfig = &CustomOriginConfig{
+			HTTPPort:               fromInt32(origin.CustomOriginConfig.HTTPPort),
+			HTTPSPort:              fromInt32(origin.CustomOriginConfig.HTTPSPort),
+			OriginProtocolPolicy:   types.String{Value: string(origin.CustomOriginConfig.OriginProtocolPolicy)},
+			OriginSslProtocols:     originSslProtocols,
+			OriginReadTimeout:      fromInt32(origin.CustomOriginConfig.OriginReadTimeout),
+			OriginKeepaliveTimeout: fromInt32(origin.CustomOriginConfig.OriginKeepaliveTimeout),
+		}
+	}
+
+	originShield := &OriginShield{
+		Enabled:            types.Bool{Value: false},
+		OriginShieldRegion: types.String{Null: true},
+	}
+	if origin.OriginShield != nil {
+		originShield = &OriginShield{
+			Enabled:            types.Bool{Value: aws.ToBool(origin.OriginShield.Enabled)},
+			OriginShieldRegion: fromString(origin.OriginShield.OriginShieldRegion),
+		}
+	}
+
+	return Origin{
+		DistributionId:        types.String{Value: distributionId},
+		Id:                    types.String{Value: aws.ToString(origin.Id)},
+		Domain:                types.String{Value: aws.ToString(origin.DomainName)},
+		OriginPath:            fromString(origin.OriginPath),
+		CustomHeaders:         customHeaders,
+		S3OriginConfig:        s3OriginConfig,
+		CustomOriginConfig:    customOriginConfig,
+		ConnectionAttempts:    fromInt32(origin.ConnectionAttempts),
+		ConnectionTimeout:     fromInt32(origin.ConnectionTimeout),
+		OriginShield:          originShield,
+		OriginAccessControlId: fromString(origin.OriginAccessControlId),
+		WaitForDeployment:     types.Bool{Null: true},
+
+		OnDeleteDependentBehaviours: types.String{Null: true},
+		ReassignTo:                  types.String{Null: true},
+		MoveInPlace:                 types.Bool{Null: true},
 	}
 }
+
+// keepUnsetDefaults keeps the prior form of settings which CloudFront
+// reports differently than they have been configured, so they do not show
+// up as drift.
+func (o Origin) keepUnsetDefaults(prior Origin) Origin {
+	o.OriginPath = keepEmptyString(prior.OriginPath, o.OriginPath)
+
+	if o.CustomHeaders == nil && prior.CustomHeaders != nil && len(prior.CustomHeaders) == 0 {
+		o.CustomHeaders = prior.CustomHeaders
+	}
+
+	if o.CustomOriginConfig != nil && prior.CustomOriginConfig != nil {
+		if o.CustomOriginConfig.OriginSslProtocols == nil && prior.CustomOriginConfig.OriginSslProtocols != nil && len(prior.CustomOriginConfig.OriginSslProtocols) == 0 {
+			o.CustomOriginConfig.OriginSslProtocols = prior.CustomOriginConfig.OriginSslProtocols
+		}
+	}
+
+	if o.S3OriginConfig != nil && prior.S3OriginConfig != nil {
+		o.S3OriginConfig.OriginAccessIdentity = keepEmptyString(prior.S3OriginConfig.OriginAccessIdentity, o.S3OriginConfig.OriginAccessIdentity)
+		// keep the form of the origin access identity that has been configured
+		priorIdentity := prior.S3OriginConfig.OriginAccessIdentity
+		if !priorIdentity.IsNull() && !priorIdentity.IsUnknown() && canonicalOriginAccessIdentity(priorIdentity.Value) == canonicalOriginAccessIdentity(o.S3OriginConfig.OriginAccessIdentity.Value) {
+			o.S3OriginConfig.OriginAccessIdentity = priorIdentity
+		}
+	}
+
+	return o
+}
+
+// canonicalOriginAccessIdentity converts a bare origin access identity ID,
+// its full path or its IAM ARN into the path CloudFront expects.
+func canonicalOriginAccessIdentity(identity string) string {
+	if identity == "" || strings.HasPrefix(identity, originAccessIdentityPath) {
+		return identity
+	}
+	return originAccessIdentityPath + strings.TrimPrefix(identity, originAccessIdentityArn)
+}
+
+// releaseDependentBehaviours removes or reassigns the cache behaviours which
+// target the origin, so it can be removed from the distribution config. With
+// the default mode it fails instead and names the blocking cache behaviours.
+// The config is only modified if no error is returned.
+func (o Origin) releaseDependentBehaviours(distributionConfig *cloudfrontTypes.DistributionConfig) ([]string, error) {
+	dependents := o.dependentBehaviours(distributionConfig)
+	defaultDependent := distributionConfig.DefaultCacheBehavior != nil && aws.ToString(distributionConfig.DefaultCacheBehavior.TargetOriginId) == o.Id.Value
+
+	mode := o.OnDeleteDependentBehaviours.Value
+	if o.OnDeleteDependentBehaviours.IsNull() {
+		mode = dependentBehavioursFail
+	}
+
+	switch mode {
+	case dependentBehavioursReassign:
+		target := o.ReassignTo.Value
+		if o.ReassignTo.IsNull() || target == o.Id.Value || indexOfOrigin(distributionConfig, target) == -1 {
+			return nil, fmt.Errorf("can not reassign the cache behaviours of origin %s to %q, it is not another origin of the distribution", o.Id.Value, target)
+		}
+
+		retargetBehaviours(distributionConfig, o.Id.Value, target)
+		return dependents, nil
+	case dependentBehavioursCascade:
+		if defaultDependent {
+			return nil, fmt.Errorf("the default cache behaviour targets origin %s and can not be deleted, reassign it instead", o.Id.Value)
+		}
+
+		var remaining []cloudfrontTypes.CacheBehavior
+		for _, behaviour := range distributionConfig.CacheBehaviors.Items {
+			if aws.ToString(behaviour.TargetOriginId) != o.Id.Value {
+				remaining = append(remaining, behaviour)
+			}
+		}
+		distributionConfig.CacheBehaviors.Items = remaining
+		distributionConfig.CacheBehaviors.Quantity = aws.Int32(int32(len(remaining)))
+		return dependents, nil
+	default:
+		if len(dependents) > 0 {
+			return nil, fmt.Errorf("origin %s is still targeted by the cache behaviours %s, delete them first or set on_delete_dependent_behaviours to %q or %q", o.Id.Value, strings.Join(dependents, ", "), dependentBehavioursCascade, dependentBehavioursReassign)
+		}
+		return nil, nil
+	}
+}
+
+// dependentBehaviours returns the path patterns of the cache behaviours
+// which target the origin, including the default cache behaviour.
+func (o Origin) dependentBehaviours(distributionConfig *cloudfrontTypes.DistributionConfig) []string {
+	var dependents []string
+	if distributionConfig.DefaultCacheBehavior != nil && aws.ToString(distributionConfig.DefaultCacheBehavior.TargetOriginId) == o.Id.Value {
+		dependents = append(dependents, "default cache behaviour")
+	}
+	for _, behaviour := range distributionConfig.CacheBehaviors.Items {
+		if aws.ToString(behaviour.TargetOriginId) == o.Id.Value {
+			dependents = append(dependents, aws.ToString(behaviour.PathPattern))
+		}
+	}
+	return dependents
+}
+
+// warnDependentBehaviours lists the cache behaviours which have been removed
+// or reassigned along with the origin.
+func warnDependentBehaviours(origin Origin, dependents []string, diags *diag.Diagnostics) {
+	if len(dependents) == 0 {
+		return
+	}
+
+	if origin.OnDeleteDependentBehaviours.Value == dependentBehavioursReassign {
+		diags.AddWarning("reassigned dependent cache behaviours", fmt.Sprintf("the cache behaviours %s targeted origin %s and have been reassigned to origin %s", strings.Join(dependents, ", "), origin.Id.Value, origin.ReassignTo.Value))
+		return
+	}
+
+	diags.AddWarning("deleted dependent cache behaviours", fmt.Sprintf("the cache behaviours %s targeted origin %s and have been deleted with it", strings.Join(dependents, ", "), origin.Id.Value))
+}
+
+// retargetBehaviours points all cache behaviours targeting one origin to
+// another one.
+func retargetBehaviours(distributionConfig *cloudfrontTypes.DistributionConfig, from string, to string) {
+	for i, behaviour := range distributionConfig.CacheBehaviors.Items {
+		if aws.ToString(behaviour.TargetOriginId) == from {
+			distributionConfig.CacheBehaviors.Items[i].TargetOriginId = aws.String(to)
+		}
+	}
+	if distributionConfig.DefaultCacheBehavior != nil && aws.ToString(distributionConfig.DefaultCacheBehavior.TargetOriginId) == from {
+		distributionConfig.DefaultCacheBehavior.TargetOriginId = aws.String(to)
+	}
+}
+
+// indexOfOrigin returns the index of the origin with the given id, or -1 if
+// there is none.
+func indexOfOrigin(distributionConfig *cloudfrontTypes.DistributionConfig, originId string) int {
+	return slices.IndexFunc(distributionConfig.Origins.Items, func(origin cloudfrontTypes.Origin) bool {
+		return aws.ToString(origin.Id) == originId
+	})
+}
@@ -0,0 +1,219 @@
+package internal
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cloudfrontTypes "github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"reflect"
+	"testing"
+)
+
+// plannedOrigin returns an S3 origin as it is planned with the defaults of
+// the schema.
+func plannedOrigin() Origin {
+	return Origin{
+		DistributionId:     types.String{Value: "E1"},
+		Id:                 types.String{Value: "impressum"},
+		Domain:             types.String{Value: "impressum.s3.eu-central-1.amazonaws.com"},
+		OriginPath:         types.String{Null: true},
+		S3OriginConfig:     &S3OriginConfig{OriginAccessIdentity: types.String{Null: true}},
+		ConnectionAttempts: types.Int64{Value: 3},
+		ConnectionTimeout:  types.Int64{Value: 10},
+		OriginShield: &OriginShield{
+			Enabled:            types.Bool{Value: false},
+			OriginShieldRegion: types.String{Null: true},
+		},
+		OriginAccessControlId: types.String{Null: true},
+		WaitForDeployment:     types.Bool{Null: true},
+
+		OnDeleteDependentBehaviours: types.String{Null: true},
+		ReassignTo:                  types.String{Null: true},
+		MoveInPlace:                 types.Bool{Null: true},
+	}
+}
+
+func TestOriginRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		plan func(origin *Origin)
+	}{
+		{
+			name: "s3 origin",
+			plan: func(origin *Origin) {},
+		},
+		{
+			name: "origin access identity id",
+			plan: func(origin *Origin) {
+				origin.S3OriginConfig.OriginAccessIdentity = types.String{Value: "E2QWRUHAPOMQZL"}
+			},
+		},
+		{
+			name: "origin access identity path",
+			plan: func(origin *Origin) {
+				origin.S3OriginConfig.OriginAccessIdentity = types.String{Value: "origin-access-identity/cloudfront/E2QWRUHAPOMQZL"}
+			},
+		},
+		{
+			name: "origin access identity arn",
+			plan: func(origin *Origin) {
+				origin.S3OriginConfig.OriginAccessIdentity = types.String{Value: "arn:aws:iam::cloudfront:user/CloudFront Origin Access Identity E2QWRUHAPOMQZL"}
+			},
+		},
+		{
+			name: "origin access control",
+			plan: func(origin *Origin) {
+				origin.OriginAccessControlId = types.String{Value: "E3OACEXAMPLE"}
+			},
+		},
+		{
+			name: "custom origin",
+			plan: func(origin *Origin) {
+				origin.Domain = types.String{Value: "example.com"}
+				origin.OriginPath = types.String{Value: "/static"}
+				origin.S3OriginConfig = nil
+				origin.CustomOriginConfig = &CustomOriginConfig{
+					HTTPPort:               types.Int64{Value: 80},
+					HTTPSPort:              types.Int64{Value: 443},
+					OriginProtocolPolicy:   types.String{Value: "https-only"},
+					OriginSslProtocols:     fromStrings([]string{"TLSv1.2", "TLSv1.1"}),
+					OriginReadTimeout:      types.Int64{Value: 30},
+					OriginKeepaliveTimeout: types.Int64{Value: 5},
+				}
+				origin.CustomHeaders = []CustomHeader{
+					{HeaderName: types.String{Value: "X-Origin-Verify"}, HeaderValue: types.String{Value: "secret"}},
+				}
+				origin.ConnectionAttempts = types.Int64{Value: 1}
+				origin.ConnectionTimeout = types.Int64{Value: 5}
+				origin.OriginShield = &OriginShield{
+					Enabled:            types.Bool{Value: true},
+					OriginShieldRegion: types.String{Value: "eu-central-1"},
+				}
+			},
+		},
+		{
+			name: "empty lists and strings",
+			plan: func(origin *Origin) {
+				origin.OriginPath = types.String{Value: ""}
+				origin.CustomHeaders = []CustomHeader{}
+				origin.S3OriginConfig.OriginAccessIdentity = types.String{Value: ""}
+			},
+		},
+		{
+			name: "empty ssl protocols",
+			plan: func(origin *Origin) {
+				origin.S3OriginConfig = nil
+				origin.CustomOriginConfig = &CustomOriginConfig{
+					HTTPPort:               types.Int64{Value: 80},
+					HTTPSPort:              types.Int64{Value: 443},
+					OriginProtocolPolicy:   types.String{Value: "http-only"},
+					OriginSslProtocols:     []types.String{},
+					OriginReadTimeout:      types.Int64{Value: 30},
+					OriginKeepaliveTimeout: types.Int64{Value: 5},
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			plan := plannedOrigin()
+			test.plan(&plan)
+
+			refreshed := OriginToResource("E1", OriginFromResource(plan)).keepUnsetDefaults(plan)
+			if !reflect.DeepEqual(refreshed, plan) {
+				t.Errorf("expected the refreshed origin to match the plan\nplan:      %+v\nrefreshed: %+v", plan, refreshed)
+			}
+		})
+	}
+}
+
+func TestCanonicalOriginAccessIdentity(t *testing.T) {
+	tests := []struct {
+		name     string
+		identity string
+		expected string
+	}{
+		{name: "empty", identity: "", expected: ""},
+		{name: "id", identity: "E2QWRUHAPOMQZL", expected: "origin-access-identity/cloudfront/E2QWRUHAPOMQZL"},
+		{name: "path", identity: "origin-access-identity/cloudfront/E2QWRUHAPOMQZL", expected: "origin-access-identity/cloudfront/E2QWRUHAPOMQZL"},
+		{name: "arn", identity: "arn:aws:iam::cloudfront:user/CloudFront Origin Access Identity E2QWRUHAPOMQZL", expected: "origin-access-identity/cloudfront/E2QWRUHAPOMQZL"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := canonicalOriginAccessIdentity(test.identity); actual != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
+
+// distributionWithBehaviours returns a distribution config with the origins
+// "impressum" and "assets" and cache behaviours targeting the given origins.
+func distributionWithBehaviours(defaultOrigin string, behaviourOrigins ...string) *cloudfrontTypes.DistributionConfig {
+	var behaviours []cloudfrontTypes.CacheBehavior
+	for i, originId := range behaviourOrigins {
+		behaviours = append(behaviours, cloudfrontTypes.CacheBehavior{
+			PathPattern:    aws.String(fmt.Sprintf("/%d/*", i)),
+			TargetOriginId: aws.String(originId),
+		})
+	}
+
+	return &cloudfrontTypes.DistributionConfig{
+		Origins: &cloudfrontTypes.Origins{
+			Items:    []cloudfrontTypes.Origin{{Id: aws.String("impressum")}, {Id: aws.String("assets")}},
+			Quantity: aws.Int32(2),
+		},
+		DefaultCacheBehavior: &cloudfrontTypes.DefaultCacheBehavior{TargetOriginId: aws.String(defaultOrigin)},
+		CacheBehaviors: &cloudfrontTypes.CacheBehaviors{
+			Items:    behaviours,
+			Quantity: aws.Int32(int32(len(behaviours))),
+		},
+	}
+}
+
+func TestReleaseDependentBehavioursCascadesOverAdjacentDependents(t *testing.T) {
+	origin := plannedOrigin()
+	origin.OnDeleteDependentBehaviours = types.String{Value: dependentBehavioursCascade}
+
+	config := distributionWithBehaviours("assets", "impressum", "impressum", "assets", "impressum", "impressum")
+	dependents, err := origin.releaseDependentBehaviours(config)
+	if err != nil {
+		t.Fatalf("expected the dependent cache behaviours to be deleted, got %v", err)
+	}
+
+	if expected := []string{"/0/*", "/1/*", "/3/*", "/4/*"}; !reflect.DeepEqual(dependents, expected) {
+		t.Errorf("expected the deleted cache behaviours %v, got %v", expected, dependents)
+	}
+	if len(config.CacheBehaviors.Items) != 1 || aws.ToString(config.CacheBehaviors.Items[0].PathPattern) != "/2/*" {
+		t.Errorf("expected only the cache behaviour /2/* to remain, got %d cache behaviours", len(config.CacheBehaviors.Items))
+	}
+	if aws.ToInt32(config.CacheBehaviors.Quantity) != 1 {
+		t.Errorf("expected a quantity of 1, got %d", aws.ToInt32(config.CacheBehaviors.Quantity))
+	}
+}
+
+func TestReleaseDependentBehavioursReassignsDefaultCacheBehaviour(t *testing.T) {
+	origin := plannedOrigin()
+	origin.OnDeleteDependentBehaviours = types.String{Value: dependentBehavioursReassign}
+	origin.ReassignTo = types.String{Value: "assets"}
+
+	config := distributionWithBehaviours("impressum", "impressum", "assets")
+	dependents, err := origin.releaseDependentBehaviours(config)
+	if err != nil {
+		t.Fatalf("expected the dependent cache behaviours to be reassigned, got %v", err)
+	}
+
+	if expected := []string{"default cache behaviour", "/0/*"}; !reflect.DeepEqual(dependents, expected) {
+		t.Errorf("expected the reassigned cache behaviours %v, got %v", expected, dependents)
+	}
+	if aws.ToString(config.DefaultCacheBehavior.TargetOriginId) != "assets" {
+		t.Errorf("expected the default cache behaviour to target assets, got %s", aws.ToString(config.DefaultCacheBehavior.TargetOriginId))
+	}
+	for _, behaviour := range config.CacheBehaviors.Items {
+		if aws.ToString(behaviour.TargetOriginId) != "assets" {
+			t.Errorf("expected the cache behaviour %s to target assets, got %s", aws.ToString(behaviour.PathPattern), aws.ToString(behaviour.TargetOriginId))
+		}
+	}
+}
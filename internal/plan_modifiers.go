@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+// requiresReplaceUnlessMoveInPlace behaves like tfsdk.RequiresReplace,
+// unless move_in_place is enabled and the resource is changed in place.
+type requiresReplaceUnlessMoveInPlace struct{}
+
+func (m requiresReplaceUnlessMoveInPlace) Description(_ context.Context) string {
+	return "If the value of this attribute changes, Terraform will destroy and recreate the resource unless move_in_place is enabled."
+}
+
+func (m requiresReplaceUnlessMoveInPlace) MarkdownDescription(ctx context.Context) string {
+	return "If the value of this attribute changes, Terraform will destroy and recreate the resource unless `move_in_place` is enabled."
+}
+
+func (m requiresReplaceUnlessMoveInPlace) Modify(ctx context.Context, req tfsdk.ModifyAttributePlanRequest, resp *tfsdk.ModifyAttributePlanResponse) {
+	var moveInPlace types.Bool
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, tftypes.NewAttributePath().WithAttributeName("move_in_place"), &moveInPlace)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if !moveInPlace.IsNull() && !moveInPlace.IsUnknown() && moveInPlace.Value {
+		return
+	}
+
+	tfsdk.RequiresReplace().Modify(ctx, req, resp)
+}
+
+// defaultValue plans the given value for an optional and computed attribute
+// which is not configured, so the plan shows what is sent to CloudFront.
+type defaultValue struct {
+	value attr.Value
+}
+
+func (m defaultValue) Description(_ context.Context) string {
+	return fmt.Sprintf("If not configured, defaults to %s.", m.value)
+}
+
+func (m defaultValue) MarkdownDescription(_ context.Context) string {
+	return fmt.Sprintf("If not configured, defaults to `%s`.", m.value)
+}
+
+func (m defaultValue) Modify(_ context.Context, req tfsdk.ModifyAttributePlanRequest, resp *tfsdk.ModifyAttributePlanResponse) {
+	if req.AttributeConfig == nil || !req.AttributeConfig.IsNull() {
+		return
+	}
+
+	resp.AttributePlan = m.value
+}
@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"sync"
+)
+
+// plannedOrigins records the origins planned in the current run, so cache
+// behaviours can target origins which do not exist in the distribution yet.
+type plannedOrigins struct {
+	mutex   sync.Mutex
+	origins map[string]map[string]bool
+}
+
+func newPlannedOrigins() *plannedOrigins {
+	return &plannedOrigins{
+		origins: map[string]map[string]bool{},
+	}
+}
+
+func (p *plannedOrigins) add(distributionId string, originId string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.origins[distributionId] == nil {
+		p.origins[distributionId] = map[string]bool{}
+	}
+	p.origins[distributionId][originId] = true
+}
+
+func (p *plannedOrigins) contains(distributionId string, originId string) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.origins[distributionId][originId]
+}
+
+// verifyPlannedOrigin reports a diagnostic on the attribute if the origin
+// neither exists in the distribution nor is planned in the current run.
+// Terraform only plans an origin before the resources referencing it, so an
+// origin given as a literal id might just not have been planned yet. Such a
+// missing origin is reported as a warning if missingIsWarning is set.
+func verifyPlannedOrigin(ctx context.Context, client *cloudfront.Client, origins *plannedOrigins, distributionId string, originId string, path *tftypes.AttributePath, missingIsWarning bool) diag.Diagnostics {
+	var diags diag.Diagnostics
+	if client == nil || origins.contains(distributionId, originId) {
+		return diags
+	}
+
+	out, err := client.GetDistributionConfig(ctx, &cloudfront.GetDistributionConfigInput{
+		Id: aws.String(distributionId),
+	})
+
+	if isNoSuchDistribution(err) {
+		diags.AddAttributeError(tftypes.NewAttributePath().WithAttributeName("distribution_id"), "Distribution not found", fmt.Sprintf("the distribution %s does not exist", distributionId))
+		return diags
+	}
+
+	if err != nil {
+		diags.AddError("failed to get distribution config", err.Error())
+		return diags
+	}
+
+	if indexOfOrigin(out.DistributionConfig, originId) == -1 {
+		summary := "Origin not found"
+		detail := fmt.Sprintf("the distribution %s has no origin with id %s and no twilliate_cloudfront_origin with this id is planned", distributionId, originId)
+		if missingIsWarning {
+			diags.AddAttributeWarning(path, summary, detail+" yet, reference the id of the twilliate_cloudfront_origin to create it first")
+		} else {
+			diags.AddAttributeError(path, summary, detail)
+		}
+	}
+
+	return diags
+}
@@ -2,11 +2,13 @@ package internal
 
 import (
 	"context"
-	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
 	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"math"
 )
 
 func New() tfsdk.Provider {
@@ -14,8 +16,13 @@ func New() tfsdk.Provider {
 }
 
 type provider struct {
-	configured bool
-	client     *cloudfront.Client
+	configured    bool
+	client        *cloudfront.Client
+	distributions *distributionUpdater
+	origins       *plannedOrigins
+	allowlist     *distributionAllowlist
+
+	waitForDeployment bool
 }
 
 // GetSchema
@@ -26,13 +33,116 @@ func (p *provider) GetSchema(_ context.Context) (tfsdk.Schema, diag.Diagnostics)
 				Type:     types.StringType,
 				Optional: true,
 			},
+			"profile": {
+				Type:     types.StringType,
+				Optional: true,
+			},
+			"shared_config_files": {
+				Type:     types.ListType{ElemType: types.StringType},
+				Optional: true,
+			},
+			"shared_credentials_files": {
+				Type:     types.ListType{ElemType: types.StringType},
+				Optional: true,
+			},
+			"access_key": {
+				Type:     types.StringType,
+				Optional: true,
+			},
+			"secret_key": {
+				Type:      types.StringType,
+				Optional:  true,
+				Sensitive: true,
+			},
+			"token": {
+				Type:      types.StringType,
+				Optional:  true,
+				Sensitive: true,
+			},
+			"assume_role":                   assumeRoleAttribute(),
+			"assume_role_with_web_identity": assumeRoleWithWebIdentityAttribute(),
+			"endpoints":                     endpointsAttribute(),
+			"skip_credentials_validation": {
+				Type:     types.BoolType,
+				Optional: true,
+			},
+			"skip_requesting_account_id": {
+				Type:        types.BoolType,
+				Optional:    true,
+				Description: "Has no effect, the account id is only requested if `allowed_account_ids` or `forbidden_account_ids` are set, which conflict with this attribute.",
+			},
+			"allowed_account_ids": {
+				Type:     types.ListType{ElemType: types.StringType},
+				Optional: true,
+			},
+			"forbidden_account_ids": {
+				Type:     types.ListType{ElemType: types.StringType},
+				Optional: true,
+			},
+			"allowed_distribution_ids": {
+				Type:     types.ListType{ElemType: types.StringType},
+				Optional: true,
+			},
+			"allowed_distribution_tags": {
+				Type:     types.MapType{ElemType: types.StringType},
+				Optional: true,
+			},
+			"max_update_attempts": {
+				Type:     types.Int64Type,
+				Optional: true,
+			},
+			"max_retries": {
+				Type:     types.Int64Type,
+				Optional: true,
+				Validators: []tfsdk.AttributeValidator{
+					int64RangeValidator{min: 0, max: math.MaxInt32},
+				},
+			},
+			"retry_mode": {
+				Type:     types.StringType,
+				Optional: true,
+				Validators: []tfsdk.AttributeValidator{
+					enumValidator([]aws.RetryMode{aws.RetryModeStandard, aws.RetryModeAdaptive}),
+				},
+			},
+			"max_requests_per_second": {
+				Type:     types.Int64Type,
+				Optional: true,
+				Validators: []tfsdk.AttributeValidator{
+					int64RangeValidator{min: 1, max: math.MaxInt32},
+				},
+			},
+			"wait_for_deployment": {
+				Type:     types.BoolType,
+				Optional: true,
+			},
 		},
 	}, nil
 }
 
 // Provider schema struct
 type providerData struct {
-	Region types.String `tfsdk:"region"`
+	Region                    types.String               `tfsdk:"region"`
+	Profile                   types.String               `tfsdk:"profile"`
+	SharedConfigFiles         []types.String             `tfsdk:"shared_config_files"`
+	SharedCredentialsFiles    []types.String             `tfsdk:"shared_credentials_files"`
+	AccessKey                 types.String               `tfsdk:"access_key"`
+	SecretKey                 types.String               `tfsdk:"secret_key"`
+	Token                     types.String               `tfsdk:"token"`
+	AssumeRole                *AssumeRole                `tfsdk:"assume_role"`
+	AssumeRoleWithWebIdentity *AssumeRoleWithWebIdentity `tfsdk:"assume_role_with_web_identity"`
+	Endpoints                 *Endpoints                 `tfsdk:"endpoints"`
+	SkipCredentialsValidation types.Bool                 `tfsdk:"skip_credentials_validation"`
+	SkipRequestingAccountId   types.Bool                 `tfsdk:"skip_requesting_account_id"`
+	AllowedAccountIds         []types.String             `tfsdk:"allowed_account_ids"`
+	ForbiddenAccountIds       []types.String             `tfsdk:"forbidden_account_ids"`
+	AllowedDistributionIds    []types.String             `tfsdk:"allowed_distribution_ids"`
+	AllowedDistributionTags   map[string]string          `tfsdk:"allowed_distribution_tags"`
+	MaxUpdateAttempts         types.Int64                `tfsdk:"max_update_attempts"`
+	MaxRetries                types.Int64                `tfsdk:"max_retries"`
+	RetryMode                 types.String               `tfsdk:"retry_mode"`
+	MaxRequestsPerSecond      types.Int64                `tfsdk:"max_requests_per_second"`
+	WaitForDeployment         types.Bool                 `tfsdk:"wait_for_deployment"`
 }
 
 func (p *provider) Configure(ctx context.Context, req tfsdk.ConfigureProviderRequest, resp *tfsdk.ConfigureProviderResponse) {
@@ -44,18 +154,70 @@ func (p *provider) Configure(ctx context.Context, req tfsdk.ConfigureProviderReq
 		return
 	}
 
-	cfg, err := config.LoadDefaultConfig(context.Background())
-	if !providerConfig.Region.IsNull() {
-		cfg.Region = providerConfig.Region.Value
+	cfg, err := loadAwsConfig(context.Background(), providerConfig)
+	if err != nil {
+		resp.Diagnostics.AddError("Unable to create aws client", "Cannot get aws config: "+err.Error())
+		return
 	}
+	client := newCloudfrontClient(cfg, providerConfig)
+
+	if !*toBool(providerConfig.SkipCredentialsValidation, false) {
+		if cfg.Credentials == nil {
+			resp.Diagnostics.AddError("Invalid aws credentials", "No aws credentials have been found")
+			return
+		}
+		if _, err := cfg.Credentials.Retrieve(ctx); err != nil {
+			resp.Diagnostics.AddError("Invalid aws credentials", "Cannot retrieve aws credentials: "+err.Error())
+			return
+		}
+	}
+
+	// the account can only be guarded if it is known
+	guardAccount := len(providerConfig.AllowedAccountIds) > 0 || len(providerConfig.ForbiddenAccountIds) > 0
+	if len(providerConfig.AllowedAccountIds) > 0 && len(providerConfig.ForbiddenAccountIds) > 0 {
+		resp.Diagnostics.AddError("Conflicting account ids", "only one of allowed_account_ids and forbidden_account_ids can be set")
+		return
+	}
+	if guardAccount && *toBool(providerConfig.SkipRequestingAccountId, false) {
+		resp.Diagnostics.AddError("Conflicting account ids", "allowed_account_ids and forbidden_account_ids need the account id, skip_requesting_account_id must not be set")
+		return
+	}
+
+	// the account id is only requested to guard the account
+	if guardAccount {
+		accountId, err := callerAccountId(ctx, newStsClient(cfg, providerConfig.Endpoints))
+		if err != nil {
+			resp.Diagnostics.AddError("Unable to request aws account id", "Cannot verify the account of the aws credentials: "+err.Error())
+			return
+		}
+
+		if err := checkAccountId(accountId, toStrings(providerConfig.AllowedAccountIds), toStrings(providerConfig.ForbiddenAccountIds)); err != nil {
+			resp.Diagnostics.AddError("Account not allowed", err.Error())
+			return
+		}
+	}
+
+	maxUpdateAttempts := defaultMaxUpdateAttempts
+	if !providerConfig.MaxUpdateAttempts.IsNull() {
+		if providerConfig.MaxUpdateAttempts.Value < 1 {
+			resp.Diagnostics.AddError("Invalid max_update_attempts", "max_update_attempts must be at least 1")
+			return
+		}
+		maxUpdateAttempts = int(providerConfig.MaxUpdateAttempts.Value)
+	}
+
+	allowlist, err := newDistributionAllowlist(client, toStrings(providerConfig.AllowedDistributionIds), providerConfig.AllowedDistributionTags)
 	if err != nil {
-		resp.Diagnostics.AddError("Unable to create aws client", "Cannot get default aws config")
+		resp.Diagnostics.AddAttributeError(tftypes.NewAttributePath().WithAttributeName("allowed_distribution_ids"), "Invalid allowed_distribution_ids", err.Error())
 		return
 	}
-	client := cloudfront.NewFromConfig(cfg)
 
 	p.configured = true
 	p.client = client
+	p.allowlist = allowlist
+	p.distributions = newDistributionUpdater(client, maxUpdateAttempts, allowlist)
+	p.origins = newPlannedOrigins()
+	p.waitForDeployment = *toBool(providerConfig.WaitForDeployment, false)
 }
 
 // GetResources - Defines provider resources
@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// tokenBucket limits the rate of requests to a number per second, allowing
+// bursts of up to one second worth of requests.
+type tokenBucket struct {
+	mutex    sync.Mutex
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(requestsPerSecond int64) *tokenBucket {
+	return &tokenBucket{
+		rate:     float64(requestsPerSecond),
+		capacity: float64(requestsPerSecond),
+		tokens:   float64(requestsPerSecond),
+		last:     time.Now(),
+	}
+}
+
+// wait takes a token from the bucket, waiting until one is available or the
+// context is done.
+func (b *tokenBucket) wait(ctx context.Context) error {
+	for {
+		b.mutex.Lock()
+		now := time.Now()
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+		b.last = now
+
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mutex.Unlock()
+			return nil
+		}
+		missing := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mutex.Unlock()
+
+		if err := sleep(ctx, missing); err != nil {
+			return err
+		}
+	}
+}
+
+// rateLimitedClient sends requests, including retries, no faster than its
+// token bucket allows.
+type rateLimitedClient struct {
+	client aws.HTTPClient
+	bucket *tokenBucket
+}
+
+func (c rateLimitedClient) Do(req *http.Request) (*http.Response, error) {
+	if err := c.bucket.wait(req.Context()); err != nil {
+		return nil, err
+	}
+	return c.client.Do(req)
+}
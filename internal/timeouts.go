@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"context"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"time"
+)
+
+type Timeouts struct {
+	Create types.String `tfsdk:"create"`
+	Update types.String `tfsdk:"update"`
+	Delete types.String `tfsdk:"delete"`
+}
+
+func timeoutsAttribute() tfsdk.Attribute {
+	return tfsdk.Attribute{
+		Optional: true,
+		Attributes: tfsdk.SingleNestedAttributes(map[string]tfsdk.Attribute{
+			"create": {
+				Type:       types.StringType,
+				Optional:   true,
+				Validators: []tfsdk.AttributeValidator{durationValidator{}},
+			},
+			"update": {
+				Type:       types.StringType,
+				Optional:   true,
+				Validators: []tfsdk.AttributeValidator{durationValidator{}},
+			},
+			"delete": {
+				Type:       types.StringType,
+				Optional:   true,
+				Validators: []tfsdk.AttributeValidator{durationValidator{}},
+			},
+		}),
+	}
+}
+
+func (t *Timeouts) create() types.String {
+	if t == nil {
+		return types.String{Null: true}
+	}
+	return t.Create
+}
+
+func (t *Timeouts) update() types.String {
+	if t == nil {
+		return types.String{Null: true}
+	}
+	return t.Update
+}
+
+func (t *Timeouts) delete() types.String {
+	if t == nil {
+		return types.String{Null: true}
+	}
+	return t.Delete
+}
+
+// withTimeout bounds the context of an operation by the configured timeout.
+// Without a timeout the operation is only bounded by terraform itself.
+func withTimeout(ctx context.Context, timeout types.String) (context.Context, context.CancelFunc) {
+	if timeout.IsNull() || timeout.IsUnknown() {
+		return context.WithCancel(ctx)
+	}
+
+	// the value has already been validated
+	duration, err := time.ParseDuration(timeout.Value)
+	if err != nil {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, duration)
+}
@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"golang.org/x/exp/slices"
+	"strings"
+	"time"
+)
+
+// durationValidator validates that a string attribute is a duration such as
+// "30m" or "1h30m".
+type durationValidator struct{}
+
+func (v durationValidator) Description(_ context.Context) string {
+	return "value must be a duration such as \"30m\" or \"1h30m\""
+}
+
+func (v durationValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v durationValidator) Validate(ctx context.Context, req tfsdk.ValidateAttributeRequest, resp *tfsdk.ValidateAttributeResponse) {
+	var value types.String
+	resp.Diagnostics.Append(tfsdk.ValueAs(ctx, req.AttributeConfig, &value)...)
+	if resp.Diagnostics.HasError() || value.IsNull() || value.IsUnknown() {
+		return
+	}
+
+	duration, err := time.ParseDuration(value.Value)
+	if err != nil || duration <= 0 {
+		resp.Diagnostics.AddAttributeError(req.AttributePath, "Invalid duration", fmt.Sprintf("%s, got %q", v.Description(ctx), value.Value))
+	}
+}
+
+// oneOfValidator validates that a string attribute is one of the given
+// values.
+type oneOfValidator struct {
+	values []string
+}
+
+func (v oneOfValidator) Description(_ context.Context) string {
+	return fmt.Sprintf("value must be one of %s", strings.Join(v.values, ", "))
+}
+
+func (v oneOfValidator) MarkdownDescription(_ context.Context) string {
+	return fmt.Sprintf("value must be one of `%s`", strings.Join(v.values, "`, `"))
+}
+
+func (v oneOfValidator) Validate(ctx context.Context, req tfsdk.ValidateAttributeRequest, resp *tfsdk.ValidateAttributeResponse) {
+	var value types.String
+	resp.Diagnostics.Append(tfsdk.ValueAs(ctx, req.AttributeConfig, &value)...)
+	if resp.Diagnostics.HasError() || value.IsNull() || value.IsUnknown() {
+		return
+	}
+
+	if !slices.Contains(v.values, value.Value) {
+		resp.Diagnostics.AddAttributeError(req.AttributePath, "Invalid value", fmt.Sprintf("%s, got %q", v.Description(ctx), value.Value))
+	}
+}
+
+// enumValidator validates that a string attribute is one of the values of
+// an SDK enum.
+func enumValidator[T ~string](values []T) oneOfValidator {
+	strs := make([]string, len(values))
+	for i, value := range values {
+		strs[i] = string(value)
+	}
+	return oneOfValidator{values: strs}
+}
+
+// elementsValidator applies a validator to every element of a list
+// attribute.
+type elementsValidator struct {
+	validator tfsdk.AttributeValidator
+}
+
+func (v elementsValidator) Description(ctx context.Context) string {
+	return "each element: " + v.validator.Description(ctx)
+}
+
+func (v elementsValidator) MarkdownDescription(ctx context.Context) string {
+	return "each element: " + v.validator.MarkdownDescription(ctx)
+}
+
+func (v elementsValidator) Validate(ctx context.Context, req tfsdk.ValidateAttributeRequest, resp *tfsdk.ValidateAttributeResponse) {
+	var list types.List
+	resp.Diagnostics.Append(tfsdk.ValueAs(ctx, req.AttributeConfig, &list)...)
+	if resp.Diagnostics.HasError() || list.IsNull() || list.IsUnknown() {
+		return
+	}
+
+	for i, element := range list.Elems {
+		elementReq := tfsdk.ValidateAttributeRequest{
+			AttributePath:   req.AttributePath.WithElementKeyInt(i),
+			AttributeConfig: element,
+			Config:          req.Config,
+		}
+		v.validator.Validate(ctx, elementReq, resp)
+	}
+}
+
+// int64RangeValidator validates that a number attribute is within the given
+// inclusive range.
+type int64RangeValidator struct {
+	min int64
+	max int64
+}
+
+func (v int64RangeValidator) Description(_ context.Context) string {
+	return fmt.Sprintf("value must be between %d and %d", v.min, v.max)
+}
+
+func (v int64RangeValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v int64RangeValidator) Validate(ctx context.Context, req tfsdk.ValidateAttributeRequest, resp *tfsdk.ValidateAttributeResponse) {
+	var value types.Int64
+	resp.Diagnostics.Append(tfsdk.ValueAs(ctx, req.AttributeConfig, &value)...)
+	if resp.Diagnostics.HasError() || value.IsNull() || value.IsUnknown() {
+		return
+	}
+
+	if value.Value < v.min || value.Value > v.max {
+		resp.Diagnostics.AddAttributeError(req.AttributePath, "Invalid value", fmt.Sprintf("%s, got %d", v.Description(ctx), value.Value))
+	}
+}